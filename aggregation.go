@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// NumericStats summarizes the values of a numeric or date field, dates
+// being Unix milliseconds. Avg, Min and Max are zero when Count is.
+type NumericStats struct {
+	Count              int
+	Min, Max, Sum, Avg float64
+}
+
+// Histogram buckets documents by the value of a numeric or date field into
+// intervals of Interval, a Unix millisecond count for dates.
+type Histogram struct {
+	Field    string
+	Interval float64
+}
+
+// DateHistogram returns a histogram over a date field by fixed intervals.
+func DateHistogram(field string, interval time.Duration) Histogram {
+	return Histogram{Field: field, Interval: float64(interval.Milliseconds())}
+}
+
+// Bucket is an interval of a histogram, starting at Key, with the number of
+// matching documents in it.
+type Bucket struct {
+	Key   float64
+	Count int
+}
+
+// Time returns the key of a bucket of a date histogram as a time.
+func (b Bucket) Time() time.Time {
+	return time.UnixMilli(int64(b.Key)).UTC()
+}
+
+// numericStats computes the statistics of fields over the documents of
+// scores.
+func (se *SearchEngine) numericStats(scores map[int]float64, fields []string) map[string]NumericStats {
+	stats := make(map[string]NumericStats, len(fields))
+	for _, field := range fields {
+		var s NumericStats
+		for docID := range scores {
+			value, ok := se.docValues.numberValue(field, docID)
+			if !ok {
+				continue
+			}
+			if s.Count == 0 || value < s.Min {
+				s.Min = value
+			}
+			if s.Count == 0 || value > s.Max {
+				s.Max = value
+			}
+			s.Sum += value
+			s.Count++
+		}
+		if s.Count > 0 {
+			s.Avg = s.Sum / float64(s.Count)
+		}
+		stats[field] = s
+	}
+	return stats
+}
+
+// histograms buckets the documents of scores, returning the buckets of
+// each histogram that hold a document in order of their keys. A histogram
+// without a positive interval has no buckets.
+func (se *SearchEngine) histograms(scores map[int]float64, histograms []Histogram) map[string][]Bucket {
+	buckets := make(map[string][]Bucket, len(histograms))
+	for _, h := range histograms {
+		if h.Interval <= 0 {
+			buckets[h.Field] = nil
+			continue
+		}
+		counts := make(map[float64]int)
+		for docID := range scores {
+			if value, ok := se.docValues.numberValue(h.Field, docID); ok {
+				counts[math.Floor(value/h.Interval)*h.Interval]++
+			}
+		}
+		histogram := make([]Bucket, 0, len(counts))
+		for key, count := range counts {
+			histogram = append(histogram, Bucket{key, count})
+		}
+		sort.Slice(histogram, func(i, j int) bool {
+			return histogram[i].Key < histogram[j].Key
+		})
+		buckets[h.Field] = histogram
+	}
+	return buckets
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// CharFilter rewrites the raw text before it is tokenized.
+type CharFilter func(text string) string
+
+// Token is a term together with where it occurred. Position counts tokens
+// from zero; Start and End are the byte offsets of the token in the text
+// after char filters ran. Tokens derived from one source token, like
+// synonyms or n-grams, share its position and offsets.
+type Token struct {
+	Term       string
+	Position   int
+	Start, End int
+}
+
+// Tokenizer splits text into tokens.
+type Tokenizer func(text string) []Token
+
+// TokenFilter transforms the token stream produced by a Tokenizer.
+type TokenFilter func(tokens []Token) []Token
+
+// Analyzer turns text into index terms by running char filters, a tokenizer
+// and token filters in that order.
+type Analyzer struct {
+	CharFilters  []CharFilter
+	Tokenizer    Tokenizer
+	TokenFilters []TokenFilter
+}
+
+func (a *Analyzer) Analyze(text string) []Token {
+	return a.analyzeFiltered(a.filterChars(text))
+}
+
+// filterChars runs the char filters on text, giving the text the offsets
+// of the tokens refer to.
+func (a *Analyzer) filterChars(text string) string {
+	for _, filter := range a.CharFilters {
+		text = filter(text)
+	}
+	return text
+}
+
+// analyzeFiltered analyzes text the char filters already ran on.
+func (a *Analyzer) analyzeFiltered(text string) []Token {
+	tokens := a.Tokenizer(text)
+	for _, filter := range a.TokenFilters {
+		tokens = filter(tokens)
+	}
+	return tokens
+}
+
+// Terms analyzes text and returns only the terms of the tokens.
+func (a *Analyzer) Terms(text string) []string {
+	tokens := a.Analyze(text)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = token.Term
+	}
+	return terms
+}
+
+// WithTokenFilters returns a copy of the analyzer with filters appended to
+// its token filters.
+func (a *Analyzer) WithTokenFilters(filters ...TokenFilter) *Analyzer {
+	tokenFilters := make([]TokenFilter, 0, len(a.TokenFilters)+len(filters))
+	tokenFilters = append(tokenFilters, a.TokenFilters...)
+	return &Analyzer{
+		CharFilters:  a.CharFilters,
+		Tokenizer:    a.Tokenizer,
+		TokenFilters: append(tokenFilters, filters...),
+	}
+}
+
+// NewStandardAnalyzer normalizes text to NFC, splits it on Unicode word
+// boundaries and lowercases the tokens.
+func NewStandardAnalyzer() *Analyzer {
+	return &Analyzer{
+		CharFilters:  []CharFilter{NFCCharFilter},
+		Tokenizer:    UnicodeTokenizer,
+		TokenFilters: []TokenFilter{LowercaseFilter},
+	}
+}
+
+// NewEnglishAnalyzer extends the standard analyzer with English stopword
+// removal and Porter stemming.
+func NewEnglishAnalyzer() *Analyzer {
+	return &Analyzer{
+		CharFilters: []CharFilter{NFCCharFilter},
+		Tokenizer:   UnicodeTokenizer,
+		TokenFilters: []TokenFilter{
+			LowercaseFilter,
+			NewStopFilter(EnglishStopWords),
+			PorterStemFilter,
+		},
+	}
+}
+
+// NewKeywordAnalyzer indexes the whole text as a single term, as is, for
+// exact matching of values like SKUs, tags or e-mail addresses.
+func NewKeywordAnalyzer() *Analyzer {
+	return &Analyzer{Tokenizer: KeywordTokenizer}
+}
+
+// NewNGramAnalyzer indexes lowercase character n-grams, so that a query
+// matches any document containing a substring of its words.
+func NewNGramAnalyzer(min, max int) *Analyzer {
+	return &Analyzer{
+		CharFilters:  []CharFilter{NFCCharFilter},
+		Tokenizer:    NewNGramTokenizer(min, max),
+		TokenFilters: []TokenFilter{LowercaseFilter},
+	}
+}
+
+// NewCJKAnalyzer indexes Chinese, Japanese and Korean text as overlapping
+// character bigrams, since those scripts do not separate words by spaces.
+func NewCJKAnalyzer() *Analyzer {
+	return &Analyzer{
+		CharFilters:  []CharFilter{NFCCharFilter},
+		Tokenizer:    CJKBigramTokenizer,
+		TokenFilters: []TokenFilter{LowercaseFilter},
+	}
+}
+
+// NFCCharFilter composes the text to Unicode normalization form C, so that
+// precomposed and decomposed spellings of a character ("é" and "e\u0301")
+// produce the same terms.
+func NFCCharFilter(text string) string {
+	return norm.NFC.String(text)
+}
+
+// NFKCCharFilter additionally folds compatibility characters such as
+// ligatures ("ﬁ"), full-width forms and superscripts to their plain
+// equivalents.
+func NFKCCharFilter(text string) string {
+	return norm.NFKC.String(text)
+}
+
+var (
+	htmlRawTextPattern = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>|<!--.*?-->`)
+	htmlTagPattern     = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// HTMLStripCharFilter removes HTML tags, comments and the contents of script
+// and style elements, and decodes character entities. Tags are replaced by a
+// space so that words in adjacent elements are not glued together.
+func HTMLStripCharFilter(text string) string {
+	text = htmlRawTextPattern.ReplaceAllString(text, " ")
+	text = htmlTagPattern.ReplaceAllString(text, " ")
+	return html.UnescapeString(text)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+func isMidLetter(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+func isMidNum(r rune) bool {
+	return r == '.' || r == ','
+}
+
+// joinsWord reports whether the punctuation r between prev and the start of
+// rest belongs to the surrounding word.
+func joinsWord(prev, r rune, rest string) bool {
+	if rest == "" {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(rest)
+	return (isMidLetter(r) && unicode.IsLetter(prev) && unicode.IsLetter(next)) ||
+		(isMidNum(r) && unicode.IsDigit(prev) && unicode.IsDigit(next))
+}
+
+// UnicodeTokenizer splits text into word tokens, loosely following the
+// Unicode word boundary rules: punctuation and hyphens separate words,
+// apostrophes are kept between letters ("don't") and decimal points between
+// digits ("3.14").
+func UnicodeTokenizer(text string) []Token {
+	var tokens []Token
+	start := -1
+	var prev rune
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 && !joinsWord(prev, r, text[i+size:]) {
+			tokens = append(tokens, Token{Term: text[start:i], Position: len(tokens), Start: start, End: i})
+			start = -1
+		}
+		prev = r
+		i += size
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Term: text[start:], Position: len(tokens), Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+// KeywordTokenizer emits the whole text as one token.
+func KeywordTokenizer(text string) []Token {
+	if text == "" {
+		return nil
+	}
+	return []Token{{Term: text, End: len(text)}}
+}
+
+// WhitespaceTokenizer splits text on whitespace only.
+func WhitespaceTokenizer(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		if !unicode.IsSpace(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			tokens = append(tokens, Token{Term: text[start:i], Position: len(tokens), Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Term: text[start:], Position: len(tokens), Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+// runeOffsets returns the byte offset of every rune in s, followed by len(s).
+func runeOffsets(s string) []int {
+	offsets := make([]int, 0, len(s)+1)
+	for i := range s {
+		offsets = append(offsets, i)
+	}
+	return append(offsets, len(s))
+}
+
+// NewNGramTokenizer returns a tokenizer emitting every character n-gram of
+// length min to max within each word. Words shorter than min are emitted
+// whole so they stay searchable. The n-grams of a word share its position.
+func NewNGramTokenizer(min, max int) Tokenizer {
+	return func(text string) []Token {
+		var grams []Token
+		for _, word := range UnicodeTokenizer(text) {
+			offsets := runeOffsets(word.Term)
+			length := len(offsets) - 1
+			if length < min {
+				grams = append(grams, word)
+				continue
+			}
+			for n := min; n <= max && n <= length; n++ {
+				for i := 0; i+n <= length; i++ {
+					grams = append(grams, Token{
+						Term:     word.Term[offsets[i]:offsets[i+n]],
+						Position: word.Position,
+						Start:    word.Start + offsets[i],
+						End:      word.Start + offsets[i+n],
+					})
+				}
+			}
+		}
+		return grams
+	}
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// CJKBigramTokenizer splits text like UnicodeTokenizer, then breaks runs of
+// CJK characters into overlapping bigrams ("日本語" becomes "日本", "本語").
+// A lone CJK character is emitted as a unigram; other scripts are untouched.
+func CJKBigramTokenizer(text string) []Token {
+	var tokens []Token
+	emit := func(word Token, from, to int) {
+		tokens = append(tokens, Token{
+			Term:     word.Term[from:to],
+			Position: len(tokens),
+			Start:    word.Start + from,
+			End:      word.Start + to,
+		})
+	}
+	for _, word := range UnicodeTokenizer(text) {
+		runes := []rune(word.Term)
+		offsets := runeOffsets(word.Term)
+		start := 0
+		for start < len(runes) {
+			end := start + 1
+			for end < len(runes) && isCJK(runes[end]) == isCJK(runes[start]) {
+				end++
+			}
+			if !isCJK(runes[start]) || end-start == 1 {
+				emit(word, offsets[start], offsets[end])
+			} else {
+				for i := start; i+1 < end; i++ {
+					emit(word, offsets[i], offsets[i+2])
+				}
+			}
+			start = end
+		}
+	}
+	return tokens
+}
+
+// NewEdgeNGramFilter returns a filter replacing every token by its prefixes
+// of length min to max. Tokens shorter than min are kept whole.
+func NewEdgeNGramFilter(min, max int) TokenFilter {
+	return func(tokens []Token) []Token {
+		var grams []Token
+		for _, token := range tokens {
+			offsets := runeOffsets(token.Term)
+			length := len(offsets) - 1
+			if length < min {
+				grams = append(grams, token)
+				continue
+			}
+			for n := min; n <= max && n <= length; n++ {
+				gram := token
+				gram.Term = token.Term[:offsets[n]]
+				grams = append(grams, gram)
+			}
+		}
+		return grams
+	}
+}
+
+// NewShingleFilter returns a filter that emits, after every token, the word
+// n-grams of size 2 to maxSize starting at it, joined by a space. The
+// original unigrams are kept, so "new york city" with maxSize 2 yields
+// "new", "new york", "york", "york city", "city". A shingle takes the
+// position of its first token.
+func NewShingleFilter(maxSize int) TokenFilter {
+	return func(tokens []Token) []Token {
+		shingles := make([]Token, 0, len(tokens)*maxSize)
+		for i, token := range tokens {
+			shingles = append(shingles, token)
+			terms := []string{token.Term}
+			for n := 2; n <= maxSize && i+n <= len(tokens); n++ {
+				last := tokens[i+n-1]
+				terms = append(terms, last.Term)
+				shingles = append(shingles, Token{
+					Term:     strings.Join(terms, " "),
+					Position: token.Position,
+					Start:    token.Start,
+					End:      last.End,
+				})
+			}
+		}
+		return shingles
+	}
+}
+
+// mapTerms replaces the term of every token with f(term).
+func mapTerms(tokens []Token, f func(string) string) []Token {
+	for i := range tokens {
+		tokens[i].Term = f(tokens[i].Term)
+	}
+	return tokens
+}
+
+// LowercaseFilter lowercases every token.
+func LowercaseFilter(tokens []Token) []Token {
+	return mapTerms(tokens, strings.ToLower)
+}
+
+// foldings covers letters that have no canonical decomposition into an ASCII
+// base letter plus combining marks.
+var foldings = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'đ': "d", 'Đ': "D", 'ł': "l", 'Ł': "L", 'þ': "th", 'Þ': "TH", 'ı': "i",
+}
+
+// ASCIIFoldingFilter removes diacritics ("café" becomes "cafe") by
+// decomposing tokens to NFD and dropping the combining marks.
+func ASCIIFoldingFilter(tokens []Token) []Token {
+	return mapTerms(tokens, foldASCII)
+}
+
+func foldASCII(term string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(term) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if folded, ok := foldings[r]; ok {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var EnglishStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if", "in",
+	"into", "is", "it", "no", "not", "of", "on", "or", "such", "that", "the",
+	"their", "then", "there", "these", "they", "this", "to", "was", "will",
+	"with",
+}
+
+// NewStopFilter returns a filter that drops the given words. Matching is
+// case-sensitive, so it normally runs after LowercaseFilter. The remaining
+// tokens keep their positions, leaving gaps where stopwords were.
+func NewStopFilter(words []string) TokenFilter {
+	stopWords := make(map[string]bool, len(words))
+	for _, word := range words {
+		stopWords[word] = true
+	}
+	return func(tokens []Token) []Token {
+		filtered := tokens[:0]
+		for _, token := range tokens {
+			if !stopWords[token.Term] {
+				filtered = append(filtered, token)
+			}
+		}
+		return filtered
+	}
+}
+
+// NewLengthFilter returns a filter that drops tokens shorter than min or
+// longer than max characters. A max of zero or less means no upper bound.
+func NewLengthFilter(min, max int) TokenFilter {
+	return func(tokens []Token) []Token {
+		filtered := tokens[:0]
+		for _, token := range tokens {
+			length := utf8.RuneCountInString(token.Term)
+			if length >= min && (max <= 0 || length <= max) {
+				filtered = append(filtered, token)
+			}
+		}
+		return filtered
+	}
+}
+
+// NewPatternFilter returns a filter that drops tokens matched by pattern.
+// Anchor the pattern to drop only whole-token matches, e.g. `^[0-9]+$` for
+// numbers or `^[0-9a-f]{32,}$` for hex hashes.
+func NewPatternFilter(pattern *regexp.Regexp) TokenFilter {
+	return func(tokens []Token) []Token {
+		filtered := tokens[:0]
+		for _, token := range tokens {
+			if !pattern.MatchString(token.Term) {
+				filtered = append(filtered, token)
+			}
+		}
+		return filtered
+	}
+}
+
+var FrenchElisions = []string{
+	"l", "m", "t", "qu", "n", "s", "j", "d", "c", "jusqu", "quoiqu", "lorsqu", "puisqu",
+}
+
+var ItalianElisions = []string{
+	"c", "l", "all", "dall", "dell", "nell", "sull", "coll", "pell", "gl", "agl",
+	"dagl", "degl", "negl", "sugl", "un", "m", "t", "s", "v", "d",
+}
+
+// NewElisionFilter returns a filter that strips an elided article and its
+// apostrophe from the start of tokens ("l'amour" becomes "amour"). Articles
+// are matched case-insensitively.
+func NewElisionFilter(articles []string) TokenFilter {
+	elisions := make(map[string]bool, len(articles))
+	for _, article := range articles {
+		elisions[strings.ToLower(article)] = true
+	}
+	return func(tokens []Token) []Token {
+		for i, token := range tokens {
+			cut := strings.IndexAny(token.Term, "'’")
+			if cut <= 0 || !elisions[strings.ToLower(token.Term[:cut])] {
+				continue
+			}
+			_, size := utf8.DecodeRuneInString(token.Term[cut:])
+			tokens[i].Term = token.Term[cut+size:]
+			tokens[i].Start += cut + size
+		}
+		return tokens
+	}
+}
+
+// PorterStemFilter reduces English tokens to their stem with PorterStem.
+func PorterStemFilter(tokens []Token) []Token {
+	return mapTerms(tokens, PorterStem)
+}
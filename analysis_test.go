@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnicodeTokenizer(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Token
+	}{
+		{"", nil},
+		{"  ,;  ", nil},
+		{"Hello, world!", []Token{
+			{Term: "Hello", Position: 0, Start: 0, End: 5},
+			{Term: "world", Position: 1, Start: 7, End: 12},
+		}},
+		{"don't stop", []Token{
+			{Term: "don't", Position: 0, Start: 0, End: 5},
+			{Term: "stop", Position: 1, Start: 6, End: 10},
+		}},
+		{"pi is 3.14, not 3,", []Token{
+			{Term: "pi", Position: 0, Start: 0, End: 2},
+			{Term: "is", Position: 1, Start: 3, End: 5},
+			{Term: "3.14", Position: 2, Start: 6, End: 10},
+			{Term: "not", Position: 3, Start: 12, End: 15},
+			{Term: "3", Position: 4, Start: 16, End: 17},
+		}},
+		{"state-of-the-art", []Token{
+			{Term: "state", Position: 0, Start: 0, End: 5},
+			{Term: "of", Position: 1, Start: 6, End: 8},
+			{Term: "the", Position: 2, Start: 9, End: 12},
+			{Term: "art", Position: 3, Start: 13, End: 16},
+		}},
+		{"'quoted'", []Token{
+			{Term: "quoted", Position: 0, Start: 1, End: 7},
+		}},
+		{"café über", []Token{
+			{Term: "café", Position: 0, Start: 0, End: 5},
+			{Term: "über", Position: 1, Start: 6, End: 11},
+		}},
+	}
+	for _, tt := range tests {
+		if got := UnicodeTokenizer(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("UnicodeTokenizer(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWhitespaceTokenizer(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"a  b\tc\n", []string{"a", "b", "c"}},
+		{"don't, stop.", []string{"don't,", "stop."}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, token := range WhitespaceTokenizer(tt.text) {
+			got = append(got, token.Term)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("WhitespaceTokenizer(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzers(t *testing.T) {
+	tests := []struct {
+		name     string
+		analyzer *Analyzer
+		text     string
+		want     []string
+	}{
+		{"standard", NewStandardAnalyzer(), "The Quick BROWN fox", []string{"the", "quick", "brown", "fox"}},
+		{"standard composes", NewStandardAnalyzer(), "Café", []string{"café"}},
+		{"standard empty", NewStandardAnalyzer(), "", []string{}},
+		{"english", NewEnglishAnalyzer(), "The dogs and the running", []string{"dog", "run"}},
+		{"keyword", NewKeywordAnalyzer(), "SKU-42 Blue", []string{"SKU-42 Blue"}},
+		{"custom", &Analyzer{
+			CharFilters:  []CharFilter{HTMLStripCharFilter},
+			Tokenizer:    WhitespaceTokenizer,
+			TokenFilters: []TokenFilter{LowercaseFilter, NewLengthFilter(3, 0)},
+		}, "<b>An</b> Old <i>Tale</i>", []string{"old", "tale"}},
+	}
+	for _, tt := range tests {
+		if got := tt.analyzer.Terms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Terms(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzerKeepsOffsetsIntoFilteredText(t *testing.T) {
+	a := NewStandardAnalyzer()
+	text := a.filterChars("Café Noir")
+	for _, token := range a.analyzeFiltered(text) {
+		if got := text[token.Start:token.End]; !strings.EqualFold(got, token.Term) {
+			t.Errorf("token %q has offsets of %q", token.Term, got)
+		}
+	}
+}
+
+func TestWithTokenFiltersCopies(t *testing.T) {
+	a := NewStandardAnalyzer()
+	stemmed := a.WithTokenFilters(PorterStemFilter)
+	if got := a.Terms("jumping"); !reflect.DeepEqual(got, []string{"jumping"}) {
+		t.Errorf("original analyzer changed: %q", got)
+	}
+	if got := stemmed.Terms("jumping"); !reflect.DeepEqual(got, []string{"jump"}) {
+		t.Errorf("stemmed analyzer: %q", got)
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"math/bits"
+	"sort"
+	"unsafe"
+)
+
+// arrayContainerMax is the cardinality above which a container switches
+// from a sorted array to a bitset; at 4096 values both take 8 KiB.
+const arrayContainerMax = 4096
+
+// Bitmap is a compressed set of uint32 values in the style of roaring
+// bitmaps: values are grouped by their upper 16 bits into containers that
+// hold the lower 16 bits either as a sorted array (sparse) or as a 65536-bit
+// bitset (dense).
+type Bitmap struct {
+	keys       []uint16
+	containers []*container
+}
+
+type container struct {
+	array  []uint16
+	bitset []uint64
+	n      int
+}
+
+func NewBitmap(values ...uint32) *Bitmap {
+	b := &Bitmap{}
+	for _, v := range values {
+		b.Add(v)
+	}
+	return b
+}
+
+func (b *Bitmap) find(key uint16) (int, bool) {
+	i := sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= key })
+	return i, i < len(b.keys) && b.keys[i] == key
+}
+
+func (b *Bitmap) Add(v uint32) {
+	key, low := uint16(v>>16), uint16(v)
+	i, ok := b.find(key)
+	if !ok {
+		b.keys = append(b.keys, 0)
+		copy(b.keys[i+1:], b.keys[i:])
+		b.keys[i] = key
+		b.containers = append(b.containers, nil)
+		copy(b.containers[i+1:], b.containers[i:])
+		b.containers[i] = &container{}
+	}
+	b.containers[i].add(low)
+}
+
+func (b *Bitmap) Contains(v uint32) bool {
+	i, ok := b.find(uint16(v >> 16))
+	return ok && b.containers[i].contains(uint16(v))
+}
+
+// Cardinality returns the number of values in the bitmap.
+func (b *Bitmap) Cardinality() int {
+	n := 0
+	for _, c := range b.containers {
+		n += c.n
+	}
+	return n
+}
+
+// ToArray returns the values in ascending order.
+func (b *Bitmap) ToArray() []uint32 {
+	values := make([]uint32, 0, b.Cardinality())
+	for it := b.Iterator(); it.Next(); {
+		values = append(values, it.Value())
+	}
+	return values
+}
+
+// And returns the intersection of b and other.
+func (b *Bitmap) And(other *Bitmap) *Bitmap {
+	result := &Bitmap{}
+	for i, j := 0, 0; i < len(b.keys) && j < len(other.keys); {
+		switch {
+		case b.keys[i] < other.keys[j]:
+			i++
+		case b.keys[i] > other.keys[j]:
+			j++
+		default:
+			if c := b.containers[i].and(other.containers[j]); c.n > 0 {
+				result.keys = append(result.keys, b.keys[i])
+				result.containers = append(result.containers, c)
+			}
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// Or returns the union of b and other.
+func (b *Bitmap) Or(other *Bitmap) *Bitmap {
+	result := &Bitmap{}
+	i, j := 0, 0
+	for i < len(b.keys) || j < len(other.keys) {
+		switch {
+		case j == len(other.keys) || (i < len(b.keys) && b.keys[i] < other.keys[j]):
+			result.keys = append(result.keys, b.keys[i])
+			result.containers = append(result.containers, b.containers[i].clone())
+			i++
+		case i == len(b.keys) || b.keys[i] > other.keys[j]:
+			result.keys = append(result.keys, other.keys[j])
+			result.containers = append(result.containers, other.containers[j].clone())
+			j++
+		default:
+			result.keys = append(result.keys, b.keys[i])
+			result.containers = append(result.containers, b.containers[i].or(other.containers[j]))
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// AndNot returns the values of b that are not in other.
+func (b *Bitmap) AndNot(other *Bitmap) *Bitmap {
+	result := &Bitmap{}
+	for i := range b.keys {
+		c := b.containers[i].clone()
+		if j, ok := other.find(b.keys[i]); ok {
+			c = b.containers[i].andNot(other.containers[j])
+		}
+		if c.n > 0 {
+			result.keys = append(result.keys, b.keys[i])
+			result.containers = append(result.containers, c)
+		}
+	}
+	return result
+}
+
+func (b *Bitmap) sizeInBytes() int {
+	size := int(unsafe.Sizeof(*b)) + cap(b.keys)*2 + cap(b.containers)*int(unsafe.Sizeof(b))
+	for _, c := range b.containers {
+		size += int(unsafe.Sizeof(*c)) + cap(c.array)*2 + cap(c.bitset)*8
+	}
+	return size
+}
+
+// BitmapIterator walks a bitmap in ascending order. Next or Advance must
+// be called before the first Value.
+type BitmapIterator struct {
+	bitmap *Bitmap
+	i, j   int
+	value  uint32
+	// base is the number of values in the containers before container i,
+	// and within the index of value in container i.
+	base, within int
+}
+
+func (b *Bitmap) Iterator() *BitmapIterator {
+	return &BitmapIterator{bitmap: b, j: -1, within: -1}
+}
+
+func (it *BitmapIterator) Next() bool {
+	for it.i < len(it.bitmap.containers) {
+		c := it.bitmap.containers[it.i]
+		high := uint32(it.bitmap.keys[it.i]) << 16
+		if c.bitset == nil {
+			it.j++
+			if it.j < len(c.array) {
+				it.within++
+				it.value = high | uint32(c.array[it.j])
+				return true
+			}
+		} else if low, ok := c.nextSet(it.j + 1); ok {
+			it.j = low
+			it.within++
+			it.value = high | uint32(low)
+			return true
+		}
+		it.nextContainer()
+	}
+	return false
+}
+
+func (it *BitmapIterator) nextContainer() {
+	it.base += it.bitmap.containers[it.i].n
+	it.i++
+	it.j = -1
+	it.within = -1
+}
+
+// Advance moves to the first value at or after target, staying put if the
+// current value is already there. It skips whole containers by their keys,
+// searches array containers and jumps to the word of target in bitset
+// ones.
+func (it *BitmapIterator) Advance(target uint32) bool {
+	b := it.bitmap
+	if it.j >= 0 && it.i < len(b.containers) && it.value >= target {
+		return true
+	}
+	key := uint16(target >> 16)
+	for it.i < len(b.containers) && b.keys[it.i] < key {
+		it.nextContainer()
+	}
+	if it.i == len(b.containers) {
+		return false
+	}
+	if b.keys[it.i] == key {
+		c := b.containers[it.i]
+		low := int(target & 0xffff)
+		if c.bitset == nil {
+			from := it.j + 1
+			k := from + sort.Search(len(c.array)-from, func(k int) bool { return int(c.array[from+k]) >= low })
+			if k < len(c.array) {
+				it.j = k
+				it.within = k
+				it.value = uint32(key)<<16 | uint32(c.array[k])
+				return true
+			}
+		} else if next, ok := c.nextSet(low); ok {
+			it.within += c.countRange(it.j+1, next)
+			it.j = next
+			it.value = uint32(key)<<16 | uint32(next)
+			return true
+		}
+		it.nextContainer()
+	}
+	return it.Next()
+}
+
+// rank returns the number of values before the current one.
+func (it *BitmapIterator) rank() int {
+	return it.base + it.within
+}
+
+func (it *BitmapIterator) Value() uint32 {
+	return it.value
+}
+
+func (c *container) add(low uint16) {
+	if c.bitset != nil {
+		if c.bitset[low>>6]&(1<<(low&63)) == 0 {
+			c.bitset[low>>6] |= 1 << (low & 63)
+			c.n++
+		}
+		return
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= low })
+	if i < len(c.array) && c.array[i] == low {
+		return
+	}
+	c.array = append(c.array, 0)
+	copy(c.array[i+1:], c.array[i:])
+	c.array[i] = low
+	c.n++
+	if c.n > arrayContainerMax {
+		c.toBitset()
+	}
+}
+
+func (c *container) contains(low uint16) bool {
+	if c.bitset != nil {
+		return c.bitset[low>>6]&(1<<(low&63)) != 0
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= low })
+	return i < len(c.array) && c.array[i] == low
+}
+
+// nextSet returns the smallest set bit at or after from in a bitset
+// container.
+func (c *container) nextSet(from int) (int, bool) {
+	for w := from >> 6; w < len(c.bitset); w++ {
+		word := c.bitset[w]
+		if w == from>>6 {
+			word &= ^uint64(0) << (from & 63)
+		}
+		if word != 0 {
+			return w<<6 + bits.TrailingZeros64(word), true
+		}
+	}
+	return 0, false
+}
+
+// countRange counts the set bits from from to to inclusive in a bitset
+// container.
+func (c *container) countRange(from, to int) int {
+	n := 0
+	for w := from >> 6; w <= to>>6; w++ {
+		word := c.bitset[w]
+		if w == from>>6 {
+			word &= ^uint64(0) << (from & 63)
+		}
+		if w == to>>6 && to&63 < 63 {
+			word &= 1<<(to&63+1) - 1
+		}
+		n += bits.OnesCount64(word)
+	}
+	return n
+}
+
+func (c *container) toBitset() {
+	c.bitset = make([]uint64, 1<<10)
+	for _, low := range c.array {
+		c.bitset[low>>6] |= 1 << (low & 63)
+	}
+	c.array = nil
+}
+
+func (c *container) bitsetCopy() []uint64 {
+	if c.bitset != nil {
+		return append([]uint64(nil), c.bitset...)
+	}
+	bitset := make([]uint64, 1<<10)
+	for _, low := range c.array {
+		bitset[low>>6] |= 1 << (low & 63)
+	}
+	return bitset
+}
+
+func (c *container) clone() *container {
+	return &container{
+		array:  append([]uint16(nil), c.array...),
+		bitset: append([]uint64(nil), c.bitset...),
+		n:      c.n,
+	}
+}
+
+// fromBitset builds a container from a bitset, switching to an array when
+// it is sparse enough.
+func fromBitset(bitset []uint64) *container {
+	n := 0
+	for _, word := range bitset {
+		n += bits.OnesCount64(word)
+	}
+	if n > arrayContainerMax {
+		return &container{bitset: bitset, n: n}
+	}
+	c := &container{array: make([]uint16, 0, n), n: n}
+	for w, word := range bitset {
+		for word != 0 {
+			c.array = append(c.array, uint16(w<<6+bits.TrailingZeros64(word)))
+			word &= word - 1
+		}
+	}
+	return c
+}
+
+func (c *container) and(other *container) *container {
+	if c.bitset == nil && other.bitset == nil {
+		result := &container{}
+		for i, j := 0, 0; i < len(c.array) && j < len(other.array); {
+			switch {
+			case c.array[i] < other.array[j]:
+				i++
+			case c.array[i] > other.array[j]:
+				j++
+			default:
+				result.array = append(result.array, c.array[i])
+				i++
+				j++
+			}
+		}
+		result.n = len(result.array)
+		return result
+	}
+	if c.bitset == nil || other.bitset == nil {
+		sparse, dense := c, other
+		if c.bitset != nil {
+			sparse, dense = other, c
+		}
+		result := &container{}
+		for _, low := range sparse.array {
+			if dense.contains(low) {
+				result.array = append(result.array, low)
+			}
+		}
+		result.n = len(result.array)
+		return result
+	}
+	bitset := make([]uint64, len(c.bitset))
+	for i := range bitset {
+		bitset[i] = c.bitset[i] & other.bitset[i]
+	}
+	return fromBitset(bitset)
+}
+
+func (c *container) or(other *container) *container {
+	if c.bitset == nil && other.bitset == nil && c.n+other.n <= arrayContainerMax {
+		result := &container{array: make([]uint16, 0, c.n+other.n)}
+		i, j := 0, 0
+		for i < len(c.array) || j < len(other.array) {
+			switch {
+			case j == len(other.array) || (i < len(c.array) && c.array[i] < other.array[j]):
+				result.array = append(result.array, c.array[i])
+				i++
+			case i == len(c.array) || c.array[i] > other.array[j]:
+				result.array = append(result.array, other.array[j])
+				j++
+			default:
+				result.array = append(result.array, c.array[i])
+				i++
+				j++
+			}
+		}
+		result.n = len(result.array)
+		return result
+	}
+	bitset := c.bitsetCopy()
+	if other.bitset != nil {
+		for i := range bitset {
+			bitset[i] |= other.bitset[i]
+		}
+	} else {
+		for _, low := range other.array {
+			bitset[low>>6] |= 1 << (low & 63)
+		}
+	}
+	return fromBitset(bitset)
+}
+
+func (c *container) andNot(other *container) *container {
+	if c.bitset == nil {
+		result := &container{}
+		for _, low := range c.array {
+			if !other.contains(low) {
+				result.array = append(result.array, low)
+			}
+		}
+		result.n = len(result.array)
+		return result
+	}
+	bitset := c.bitsetCopy()
+	if other.bitset != nil {
+		for i := range bitset {
+			bitset[i] &^= other.bitset[i]
+		}
+	} else {
+		for _, low := range other.array {
+			bitset[low>>6] &^= 1 << (low & 63)
+		}
+	}
+	return fromBitset(bitset)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// bitmapSets are value sets covering array containers, bitset containers
+// and several containers.
+var bitmapSets = map[string][]uint32{
+	"empty":  nil,
+	"sparse": {1, 5, 9, 70000, 1 << 31},
+	"dense":  rangeValues(0, 10000, 2),
+	"mixed":  append(rangeValues(3, 9000, 3), rangeValues(65536, 66000, 1)...),
+}
+
+func rangeValues(from, to, step uint32) []uint32 {
+	var values []uint32
+	for v := from; v < to; v += step {
+		values = append(values, v)
+	}
+	return values
+}
+
+// setValues returns the values of set in ascending order, never nil.
+func setValues(set map[uint32]bool) []uint32 {
+	values := make([]uint32, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values
+}
+
+func toSet(values []uint32) map[uint32]bool {
+	set := make(map[uint32]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func TestBitmap(t *testing.T) {
+	for name, values := range bitmapSets {
+		b := NewBitmap(values...)
+		want := setValues(toSet(values))
+		if got := b.ToArray(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ToArray = %v, want %v", name, got, want)
+		}
+		if b.Cardinality() != len(want) {
+			t.Errorf("%s: Cardinality = %d, want %d", name, b.Cardinality(), len(want))
+		}
+		for _, v := range []uint32{0, 1, 2, 9, 70000, 1 << 31} {
+			if got := b.Contains(v); got != toSet(values)[v] {
+				t.Errorf("%s: Contains(%d) = %v", name, v, got)
+			}
+		}
+	}
+}
+
+func TestBitmapSetOperations(t *testing.T) {
+	for aName, a := range bitmapSets {
+		for bName, b := range bitmapSets {
+			x, y := NewBitmap(a...), NewBitmap(b...)
+			xs, ys := toSet(a), toSet(b)
+			and, or, andNot := map[uint32]bool{}, map[uint32]bool{}, map[uint32]bool{}
+			for v := range xs {
+				or[v] = true
+				if ys[v] {
+					and[v] = true
+				} else {
+					andNot[v] = true
+				}
+			}
+			for v := range ys {
+				or[v] = true
+			}
+			tests := []struct {
+				op   string
+				got  *Bitmap
+				want map[uint32]bool
+			}{
+				{"And", x.And(y), and},
+				{"Or", x.Or(y), or},
+				{"AndNot", x.AndNot(y), andNot},
+			}
+			for _, tt := range tests {
+				if got := tt.got.ToArray(); !reflect.DeepEqual(got, setValues(tt.want)) {
+					t.Errorf("%s %s %s: got %d values, want %d", aName, tt.op, bName, len(got), len(tt.want))
+				}
+				if tt.got.Cardinality() != len(tt.want) {
+					t.Errorf("%s %s %s: Cardinality = %d, want %d", aName, tt.op, bName, tt.got.Cardinality(), len(tt.want))
+				}
+			}
+			if got := x.ToArray(); !reflect.DeepEqual(got, setValues(xs)) {
+				t.Errorf("%s with %s: operands changed", aName, bName)
+			}
+		}
+	}
+}
+
+func TestBitmapPostingsRoundTrip(t *testing.T) {
+	docIDs := []int{0, 2, 3, 70000, 70001, 200000}
+	for i := 4; i < 9000; i += 2 {
+		docIDs = append(docIDs, i)
+	}
+	sort.Ints(docIDs)
+	postings := makePostings(docIDs...)
+	b := NewBitmapPostings(postings)
+	if b.Len() != len(postings) {
+		t.Errorf("Len = %d, want %d", b.Len(), len(postings))
+	}
+	if got := decodeAll(b); !reflect.DeepEqual(got, postings) {
+		t.Error("decoded postings differ")
+	}
+	if got := b.Docs().Cardinality(); got != len(postings) {
+		t.Errorf("Docs has %d documents, want %d", got, len(postings))
+	}
+}
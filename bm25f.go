@@ -0,0 +1,59 @@
+package main
+
+import "math"
+
+// bm25fScores scores text across fields with BM25F: the frequencies of a
+// term in the fields, each weighted by its boost and normalized by the
+// length of the field, add up to one frequency, which saturates once per
+// term rather than once per field. A document with a term in its title and
+// its body thus scores less than twice a document with it in one field.
+// The idf counts the documents with the term in any of the fields.
+func (se *SearchEngine) bm25fScores(fields []string, boosts []float64, text string, params BM25) map[int]float64 {
+	segments := se.searchableSegments()
+	numDocs := float64(maxDoc(segments))
+
+	// fieldTerms[i] holds the terms of text as analyzed for fields[i].
+	fieldTerms := make([]map[string]bool, len(fields))
+	var terms []string
+	seen := make(map[string]bool)
+	for i, field := range fields {
+		fieldTerms[i] = make(map[string]bool)
+		for _, token := range se.queryAnalyzer(field).Analyze(text) {
+			fieldTerms[i][token.Term] = true
+			if !seen[token.Term] {
+				seen[token.Term] = true
+				terms = append(terms, token.Term)
+			}
+		}
+	}
+
+	scores := make(map[int]float64)
+	for _, term := range terms {
+		tf := make(map[int]float64)
+		for i, field := range fields {
+			if !fieldTerms[i][term] {
+				continue
+			}
+			avgLength := se.avgFieldLength(field)
+			for _, postings := range termPostings(segments, field, term) {
+				for it := postings.Iterator(); it.Next(); {
+					posting := it.Posting()
+					if se.isDeleted(posting.DocID) {
+						continue
+					}
+					norm := 1 - params.B
+					if avgLength > 0 {
+						norm += params.B * float64(se.fieldDocLength(field, posting.DocID)) / avgLength
+					}
+					tf[posting.DocID] += boosts[i] * float64(posting.Freq) / norm
+				}
+			}
+		}
+		df := float64(len(tf))
+		idf := math.Log(1 + (numDocs-df+0.5)/(df+0.5))
+		for docID, freq := range tf {
+			scores[docID] += idf * freq * (params.K1 + 1) / (freq + params.K1)
+		}
+	}
+	return scores
+}
@@ -0,0 +1,76 @@
+package main
+
+// The query types can be put together directly in Go, which avoids quoting
+// user input into a query string. The helpers below cover the common
+// shapes:
+//
+//	q := And(
+//		Or(MatchQuery{Field: "title", Text: userInput}, PhraseQuery{Text: userInput}),
+//		NewRangeQuery("price", 10, 50),
+//		Not(TermQuery{Field: "status", Term: "draft"}),
+//	)
+//	results := se.SearchQuery(q, SearchOptions{Size: 20})
+
+// TermQuery matches the documents containing Term exactly as given, without
+// analysis, which suits keyword fields. An empty Field matches every indexed
+// field. Scorer overrides the scorer of the index.
+type TermQuery struct {
+	Field  string
+	Term   string
+	Scorer Scorer
+}
+
+func (q TermQuery) score(se *SearchEngine) map[int]float64 {
+	return se.scoreFields(q.Field, func(field string) map[int]float64 {
+		return se.scoreTokens(field, []string{q.Term}, q.Scorer)
+	})
+}
+
+func (q TermQuery) String() string {
+	return fieldPrefix(q.Field) + q.Term
+}
+
+// And returns a query matching the documents that match every query.
+func And(queries ...Query) Query {
+	var q BooleanQuery
+	for _, clause := range queries {
+		b, ok := clause.(BooleanQuery)
+		switch {
+		case ok && isNegation(b):
+			q.MustNot = append(q.MustNot, b.MustNot...)
+		case ok && isFilter(b):
+			q.Filter = append(q.Filter, b.Filter...)
+		default:
+			q.Must = append(q.Must, clause)
+		}
+	}
+	return q
+}
+
+// Or returns a query matching the documents that match any of queries.
+func Or(queries ...Query) Query {
+	return BooleanQuery{Should: queries}
+}
+
+// Not returns a query matching the documents that do not match q. Within
+// And it excludes the matches of q from the other clauses.
+func Not(q Query) Query {
+	return BooleanQuery{MustNot: []Query{q}}
+}
+
+// Filter returns a query matching the documents that match every query,
+// with a score of zero; within And it narrows down the other clauses
+// without changing their scores.
+func Filter(queries ...Query) Query {
+	return BooleanQuery{Filter: queries}
+}
+
+// isFilter reports whether q only filters documents.
+func isFilter(q BooleanQuery) bool {
+	return len(q.Must) == 0 && len(q.Should) == 0 && len(q.MustNot) == 0 && len(q.Filter) > 0
+}
+
+// Boost returns q with its scores multiplied by boost.
+func Boost(q Query, boost float64) Query {
+	return BoostQuery{Query: q, Boost: boost}
+}
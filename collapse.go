@@ -0,0 +1,53 @@
+package main
+
+// Collapse groups results by the keyword doc value or stored text of Field,
+// e.g. to show a single hit per author. Documents without a value are not
+// grouped.
+type Collapse struct {
+	Field string
+	// InnerHits is the number of best documents of its group to return
+	// with each hit.
+	InnerHits int
+}
+
+// collapse returns the best document of each group of scores in the order
+// of better, with its score, and the documents of each group by value.
+func (se *SearchEngine) collapse(scores map[int]float64, field string, better func(a, b int) bool) (map[int]float64, map[string][]int) {
+	groups := make(map[string][]int)
+	best := make(map[string]int)
+	collapsed := make(map[int]float64)
+	for docID, score := range scores {
+		value := se.sortText(field, docID)
+		if value == "" {
+			collapsed[docID] = score
+			continue
+		}
+		groups[value] = append(groups[value], docID)
+		if head, ok := best[value]; !ok || better(docID, head) {
+			best[value] = docID
+		}
+	}
+	for _, docID := range best {
+		collapsed[docID] = scores[docID]
+	}
+	return collapsed, groups
+}
+
+// innerHits returns the n best documents of group in the order of better,
+// or docID alone if it has no group.
+func (se *SearchEngine) innerHits(scores map[int]float64, group []int, docID, n int, better func(a, b int) bool) []Hit {
+	if len(group) == 0 {
+		group = []int{docID}
+	}
+	members := make(map[int]float64, len(group))
+	for _, member := range group {
+		members[member] = scores[member]
+	}
+	var hits []Hit
+	for _, member := range topDocIDs(members, n, better) {
+		doc := se.documents[member]
+		doc.Score = scores[member]
+		hits = append(hits, Hit{Document: doc, rawScore: doc.Score})
+	}
+	return hits
+}
@@ -0,0 +1,138 @@
+package main
+
+// Count returns the number of documents matching a query in the syntax of
+// ParseQuery, without scoring, sorting or loading them.
+func (se *SearchEngine) Count(query string) int {
+	return se.CountQuery(se.parseSearch(query, se.defaultField))
+}
+
+// CountQuery returns the number of documents matching a query tree, after
+// Config.Rewrite if set.
+func (se *SearchEngine) CountQuery(q Query) int {
+	if se.rewrite != nil {
+		q = se.rewrite(q)
+	}
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+	return se.matchDocs(q).Cardinality()
+}
+
+// matchDocs returns the documents matching q. Term and match queries and
+// boolean combinations of them are answered from the postings alone,
+// filters from the filter cache, and queries that only change scores by
+// what they wrap; the other queries are scored. se.rw must be held.
+func (se *SearchEngine) matchDocs(q Query) *Bitmap {
+	switch q := q.(type) {
+	case MatchAllQuery:
+		return se.liveDocs()
+	case MatchNoneQuery:
+		return NewBitmap()
+	case TermQuery:
+		return se.termDocs(q.Field, []string{q.Term})
+	case TermsQuery:
+		return se.termDocs(q.Field, q.Terms)
+	case BoolFieldQuery:
+		return q.docs(se)
+	case MatchQuery:
+		if q.MinimumShouldMatch != "" {
+			break
+		}
+		docs := NewBitmap()
+		fields := []string{q.Field}
+		if q.Field == "" {
+			fields = se.indexedFields()
+		}
+		for _, field := range fields {
+			if se.languageAnalyzed(field) {
+				return scoredDocs(q.score(se))
+			}
+			docs = docs.Or(se.termDocs(field, se.queryAnalyzer(field).Terms(q.Text)))
+		}
+		return docs
+	case BooleanQuery:
+		if q.MinimumShouldMatch != "" {
+			break
+		}
+		var docs *Bitmap
+		for _, clause := range q.Must {
+			if docs == nil {
+				docs = se.matchDocs(clause)
+			} else {
+				docs = docs.And(se.matchDocs(clause))
+			}
+		}
+		if docs == nil && len(q.Should) > 0 {
+			docs = NewBitmap()
+			for _, clause := range q.Should {
+				docs = docs.Or(se.matchDocs(clause))
+			}
+		}
+		for _, clause := range q.Filter {
+			if docs == nil {
+				docs = se.filterDocs(clause)
+			} else {
+				docs = docs.And(se.filterDocs(clause))
+			}
+		}
+		if docs == nil {
+			docs = se.liveDocs()
+		}
+		for _, clause := range q.MustNot {
+			docs = docs.AndNot(se.matchDocs(clause))
+		}
+		return docs
+	case BoostQuery:
+		return se.matchDocs(q.Query)
+	case RecencyQuery:
+		return se.matchDocs(q.Query)
+	case FunctionScoreQuery:
+		return se.matchDocs(q.Query)
+	case ScriptScoreQuery:
+		return se.matchDocs(q.Query)
+	case ProximityQuery:
+		return se.matchDocs(q.Query)
+	}
+	return scoredDocs(q.score(se))
+}
+
+// termDocs returns the live documents containing any of terms in field, or
+// in any indexed field if field is empty.
+func (se *SearchEngine) termDocs(field string, terms []string) *Bitmap {
+	fields := []string{field}
+	if field == "" {
+		fields = se.indexedFields()
+	}
+	docs := NewBitmap()
+	segments := se.searchableSegments()
+	for _, field := range fields {
+		for _, term := range terms {
+			for _, postings := range termPostings(segments, field, term) {
+				for it := postings.Iterator(); it.Next(); {
+					if docID := it.Posting().DocID; !se.isDeleted(docID) {
+						docs.Add(uint32(docID))
+					}
+				}
+			}
+		}
+	}
+	return docs
+}
+
+// liveDocs returns every document that is not deleted.
+func (se *SearchEngine) liveDocs() *Bitmap {
+	docs := NewBitmap()
+	for docID := range se.documents {
+		if !se.isDeleted(docID) {
+			docs.Add(uint32(docID))
+		}
+	}
+	return docs
+}
+
+func scoredDocs(scores map[int]float64) *Bitmap {
+	docs := NewBitmap()
+	for docID := range scores {
+		docs.Add(uint32(docID))
+	}
+	return docs
+}
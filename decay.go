@@ -0,0 +1,43 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// RecencyQuery multiplies the scores of Query by 0.5^(age/HalfLife), where
+// age is how long before Now the date in Field is, so a document loses half
+// its score every HalfLife. The field needs date doc values
+// (FieldOptions.DocValues). Documents without a date, or dated after Now,
+// keep their scores. A zero Now is the time of the search.
+type RecencyQuery struct {
+	Query    Query
+	Field    string
+	HalfLife time.Duration
+	Now      time.Time
+}
+
+func (q RecencyQuery) score(se *SearchEngine) map[int]float64 {
+	now := q.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	scores := q.Query.score(se)
+	if q.HalfLife <= 0 {
+		return scores
+	}
+	for docID := range scores {
+		millis, ok := se.docValues.numberValue(q.Field, docID)
+		if !ok {
+			continue
+		}
+		if age := float64(now.UnixMilli()) - millis; age > 0 {
+			scores[docID] *= math.Pow(0.5, age/(float64(q.HalfLife)/float64(time.Millisecond)))
+		}
+	}
+	return scores
+}
+
+func (q RecencyQuery) String() string {
+	return "recency(" + q.Query.String() + ", " + q.Field + ", " + q.HalfLife.String() + ")"
+}
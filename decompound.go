@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	decompoundMinWordSize    = 5
+	decompoundMinSubwordSize = 2
+	decompoundMaxSubwordSize = 15
+)
+
+// NewDecompoundFilter returns a filter that splits compound words into the
+// dictionary words they contain: with "donau", "dampf" and "schiff" in the
+// dictionary, "Donaudampfschiff" also yields those three. The compound
+// itself is kept and its parts share its position and offsets. At each
+// character only the longest dictionary word starting there is emitted.
+// Matching is case-insensitive.
+func NewDecompoundFilter(dictionary []string) TokenFilter {
+	words := make(map[string]bool, len(dictionary))
+	for _, word := range dictionary {
+		words[strings.ToLower(word)] = true
+	}
+	return func(tokens []Token) []Token {
+		decompounded := make([]Token, 0, len(tokens))
+		for _, token := range tokens {
+			decompounded = append(decompounded, token)
+			if utf8.RuneCountInString(token.Term) < decompoundMinWordSize {
+				continue
+			}
+			runes := []rune(strings.ToLower(token.Term))
+			for i := range runes {
+				longest := ""
+				for n := decompoundMinSubwordSize; n <= decompoundMaxSubwordSize && i+n <= len(runes); n++ {
+					if candidate := string(runes[i : i+n]); words[candidate] {
+						longest = candidate
+					}
+				}
+				if longest != "" && longest != string(runes) {
+					part := token
+					part.Term = longest
+					decompounded = append(decompounded, part)
+				}
+			}
+		}
+		return decompounded
+	}
+}
+
+// LoadWordList reads one word per line, skipping blank lines and lines
+// starting with '#', e.g. a decompounding dictionary or a stopword list.
+func LoadWordList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words, scanner.Err()
+}
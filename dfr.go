@@ -0,0 +1,97 @@
+package main
+
+import "math"
+
+// DFR is a Divergence From Randomness scorer: a term scores by how much
+// its frequency in a document diverges from what a random distribution
+// over the collection would give (the basic model), tempered by how
+// informative one more occurrence is (the after effect), with the
+// frequency first normalized by the length of the field. The zero value
+// is the InL2 model.
+type DFR struct {
+	BasicModel    DFRBasicModel
+	AfterEffect   DFRAfterEffect
+	Normalization DFRNormalization
+	// C scales the length normalization, 1 if zero.
+	C float64
+}
+
+// DFRBasicModel is the randomness model of a DFR scorer.
+type DFRBasicModel int
+
+const (
+	// BasicIn is the inverse document frequency model.
+	BasicIn DFRBasicModel = iota
+	// BasicIne is the inverse expected document frequency model.
+	BasicIne
+	// BasicIF is the inverse term frequency model.
+	BasicIF
+	// BasicG is the geometric approximation of Bose-Einstein.
+	BasicG
+)
+
+// DFRAfterEffect is the first normalization of a DFR scorer.
+type DFRAfterEffect int
+
+const (
+	// AfterEffectL is Laplace's law of succession.
+	AfterEffectL DFRAfterEffect = iota
+	// AfterEffectB is the ratio of two Bernoulli processes.
+	AfterEffectB
+	// AfterEffectNone leaves the score of the basic model as is.
+	AfterEffectNone
+)
+
+// DFRNormalization is the term frequency normalization of a DFR scorer.
+type DFRNormalization int
+
+const (
+	// NormalizationH2 assumes term density decreases with length:
+	// tf * log2(1 + c * avgdl / dl).
+	NormalizationH2 DFRNormalization = iota
+	// NormalizationH1 assumes a uniform term density: tf * c * avgdl / dl.
+	NormalizationH1
+	// NormalizationNone uses the raw term frequency: tf.
+	NormalizationNone
+)
+
+func (s DFR) Score(term TermStats, doc DocStats, field FieldStats) float64 {
+	c := s.C
+	if c <= 0 {
+		c = 1
+	}
+	tfn := float64(doc.Freq)
+	if dl := float64(doc.Length); dl > 0 {
+		switch s.Normalization {
+		case NormalizationH2:
+			tfn *= math.Log2(1 + c*field.AvgLength/dl)
+		case NormalizationH1:
+			tfn *= c * field.AvgLength / dl
+		}
+	}
+
+	n := float64(field.DocCount)
+	df := float64(term.DocFreq)
+	f := float64(term.TotalTermFreq)
+	var basic float64
+	switch s.BasicModel {
+	case BasicIne:
+		ne := n * (1 - math.Pow((n-1)/n, f))
+		basic = tfn * math.Log2((n+1)/(ne+0.5))
+	case BasicIF:
+		basic = tfn * math.Log2((n+1)/(f+0.5))
+	case BasicG:
+		lambda := f / n
+		basic = math.Log2(1+lambda) + tfn*math.Log2((1+lambda)/lambda)
+	default:
+		basic = tfn * math.Log2((n+1)/(df+0.5))
+	}
+
+	switch s.AfterEffect {
+	case AfterEffectL:
+		return basic / (tfn + 1)
+	case AfterEffectB:
+		return basic * (f + 1) / (df * (tfn + 1))
+	}
+	return basic
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Document is the unit of indexing. ID is chosen by the application and
+// identifies the document in results and in updates.
+type Document struct {
+	ID string
+	// Fields holds the values of the document by field name, such as its
+	// title, body, author or publication date: text as a string, numbers
+	// as any int or float type, dates as a time.Time. A slice holds several
+	// values of a field, such as tags or authors, each indexed on its own
+	// so that a term matches any of them. Every field is indexed as text,
+	// dates in RFC 3339, and kept as given in results; FieldOptions change
+	// how a field is indexed and kept. A nested map[string]interface{},
+	// as decoded from a JSON object, is flattened into one field per value
+	// named by its path, such as author.name.
+	Fields   map[string]interface{}
+	Language string
+	// Metadata is kept with the document and returned in results but never
+	// indexed, validated or searched: URLs, thumbnails, external IDs and
+	// the like.
+	Metadata map[string]interface{}
+	// Boost multiplies the score of the document in every search, to rank
+	// it by popularity or editorial weight as well; zero means 1.
+	Boost float64
+	Score float64
+}
+
+// flattenFields returns fields with the values of nested objects moved to
+// fields named by their dotted paths, or fields itself if there are none.
+func flattenFields(fields map[string]interface{}) map[string]interface{} {
+	nested := false
+	for _, value := range fields {
+		if _, ok := value.(map[string]interface{}); ok {
+			nested = true
+			break
+		}
+	}
+	if !nested {
+		return fields
+	}
+	flat := make(map[string]interface{}, len(fields))
+	var flatten func(prefix string, fields map[string]interface{})
+	flatten = func(prefix string, fields map[string]interface{}) {
+		for name, value := range fields {
+			if object, ok := value.(map[string]interface{}); ok {
+				flatten(prefix+name+".", object)
+			} else {
+				flat[prefix+name] = value
+			}
+		}
+	}
+	flatten("", fields)
+	return flat
+}
+
+// valueSeparator joins the texts of the values of a multi-valued field.
+const valueSeparator = ", "
+
+// valueGap is the number of positions left between the values of a
+// multi-valued field, so that phrases do not match across two values.
+const valueGap = 100
+
+// Field returns the text of the named field, "" if the document has none.
+// The texts of the values of a multi-valued field are joined by ", ".
+func (doc Document) Field(name string) string {
+	value, ok := doc.Fields[name]
+	if !ok {
+		return ""
+	}
+	return fieldText(value)
+}
+
+// FieldValues returns the texts of the values of the named field, one per
+// element if it holds a slice.
+func (doc Document) FieldValues(name string) []string {
+	value, ok := doc.Fields[name]
+	if !ok {
+		return nil
+	}
+	values := fieldValues(value)
+	texts := make([]string, len(values))
+	for i, value := range values {
+		texts[i] = fieldText(value)
+	}
+	return texts
+}
+
+// fieldValues returns the elements of a slice field value, or the value
+// alone if it is not a slice.
+func fieldValues(value interface{}) []interface{} {
+	if values, ok := sliceValues(value); ok {
+		return values
+	}
+	return []interface{}{value}
+}
+
+// sliceValues returns the elements of value if it is a slice other than
+// []byte.
+func sliceValues(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []byte:
+		return nil, false
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values, true
+}
+
+// fieldText returns the text a field value is indexed as.
+func fieldText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	}
+	if values, ok := sliceValues(value); ok {
+		texts := make([]string, len(values))
+		for i, value := range values {
+			texts[i] = fieldText(value)
+		}
+		return strings.Join(texts, valueSeparator)
+	}
+	return fmt.Sprint(value)
+}
+
+// text returns the text of every field of the document, in field name
+// order, e.g. to detect its language.
+func (doc Document) text() string {
+	names := documentFields(doc)
+	texts := make([]string, len(names))
+	for i, name := range names {
+		texts[i] = doc.Field(name)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// documentFields returns the names of the fields of a document in sorted
+// order.
+func documentFields(doc Document) []string {
+	names := make([]string, 0, len(doc.Fields))
+	for name := range doc.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// analyzeValues analyzes the values of a field one by one into a single
+// token stream, as if their texts were joined by valueSeparator, with
+// valueGap positions between the tokens of consecutive values.
+func analyzeValues(analyzer *Analyzer, values []string) []Token {
+	if len(values) == 1 {
+		return analyzer.Analyze(values[0])
+	}
+	var tokens []Token
+	position, offset := 0, 0
+	for _, value := range values {
+		value = analyzer.filterChars(value)
+		last := position
+		for _, token := range analyzer.analyzeFiltered(value) {
+			token.Position += position
+			token.Start += offset
+			token.End += offset
+			tokens = append(tokens, token)
+			last = token.Position
+		}
+		position = last + valueGap
+		offset += len(value) + len(valueSeparator)
+	}
+	return tokens
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DocValuesType selects the column a field's values are kept in besides
+// the inverted index. Doc values are stored by document rather than by
+// term, which is what sorting, range filters and facets need.
+type DocValuesType int
+
+const (
+	NoDocValues DocValuesType = iota
+	// NumericDocValues parses the field as a float64.
+	NumericDocValues
+	// DateDocValues parses the field with FieldOptions.DateLayouts and
+	// keeps it as Unix milliseconds.
+	DateDocValues
+	// KeywordDocValues keeps the field value as is.
+	KeywordDocValues
+)
+
+// DefaultDateLayouts are the layouts of date fields without
+// FieldOptions.DateLayouts: RFC 3339 timestamps and yyyy-mm-dd dates.
+var DefaultDateLayouts = []string{time.RFC3339Nano, "2006-01-02"}
+
+// parseDate parses a date with the first of layouts that fits it, or with
+// DefaultDateLayouts if there are none.
+func parseDate(value string, layouts []string) (time.Time, bool) {
+	if len(layouts) == 0 {
+		layouts = DefaultDateLayouts
+	}
+	value = strings.TrimSpace(value)
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// docValues holds one column per field, indexed by internal ID. Numeric
+// and date columns use NaN for documents without a value, including values
+// that do not parse, and hold a single value, as validation rejects
+// several; keyword columns hold every value. Columns are only ever written
+// past the end of what a snapshot has seen. Boolean fields also keep the
+// documents with each value in bitmaps, false first.
+type docValues struct {
+	numeric map[string][]float64
+	keyword map[string][][]string
+	bools   map[string][2]*Bitmap
+}
+
+func newDocValues() docValues {
+	return docValues{
+		numeric: make(map[string][]float64),
+		keyword: make(map[string][][]string),
+		bools:   make(map[string][2]*Bitmap),
+	}
+}
+
+func (dv docValues) clone() docValues {
+	clone := newDocValues()
+	for field, column := range dv.numeric {
+		clone.numeric[field] = column
+	}
+	for field, column := range dv.keyword {
+		clone.keyword[field] = column
+	}
+	// Unlike the columns, the bitmaps are written in place.
+	for field, bitmaps := range dv.bools {
+		clone.bools[field] = [2]*Bitmap{bitmaps[0].Or(NewBitmap()), bitmaps[1].Or(NewBitmap())}
+	}
+	return clone
+}
+
+// add records the values of a field of a document. Dates given as
+// time.Time are kept as is, whatever the layouts of the field.
+func (dv docValues) add(docID int, field string, values []interface{}, options FieldOptions) {
+	if options.Type == FieldBool {
+		bitmaps, ok := dv.bools[field]
+		if !ok {
+			bitmaps = [2]*Bitmap{NewBitmap(), NewBitmap()}
+			dv.bools[field] = bitmaps
+		}
+		for _, value := range values {
+			if fieldText(value) == "true" {
+				bitmaps[1].Add(uint32(docID))
+			} else {
+				bitmaps[0].Add(uint32(docID))
+			}
+		}
+	}
+	if options.DocValues == KeywordDocValues {
+		var keywords []string
+		for _, value := range values {
+			if text := fieldText(value); text != "" {
+				keywords = append(keywords, text)
+			}
+		}
+		column := dv.keyword[field]
+		for len(column) <= docID {
+			column = append(column, nil)
+		}
+		column[docID] = keywords
+		dv.keyword[field] = column
+		return
+	}
+	if len(values) == 0 {
+		return
+	}
+	switch options.DocValues {
+	case NumericDocValues:
+		n, err := strconv.ParseFloat(strings.TrimSpace(fieldText(values[0])), 64)
+		if err != nil {
+			n = math.NaN()
+		}
+		dv.setNumeric(field, docID, n)
+	case DateDocValues:
+		n := math.NaN()
+		if t, ok := values[0].(time.Time); ok {
+			n = float64(t.UnixMilli())
+		} else if t, ok := parseDate(fieldText(values[0]), options.DateLayouts); ok {
+			n = float64(t.UnixMilli())
+		}
+		dv.setNumeric(field, docID, n)
+	}
+}
+
+func (dv docValues) setNumeric(field string, docID int, n float64) {
+	column := dv.numeric[field]
+	for len(column) <= docID {
+		column = append(column, math.NaN())
+	}
+	column[docID] = n
+	dv.numeric[field] = column
+}
+
+// numberValue returns the numeric or date value of a field in a document.
+func (dv docValues) numberValue(field string, docID int) (float64, bool) {
+	column := dv.numeric[field]
+	if docID >= len(column) || math.IsNaN(column[docID]) {
+		return 0, false
+	}
+	return column[docID], true
+}
+
+// boolDocs returns the documents with value among the values of a boolean
+// field, deleted ones included. It must not be modified.
+func (dv docValues) boolDocs(field string, value bool) *Bitmap {
+	bitmaps, ok := dv.bools[field]
+	if !ok {
+		return NewBitmap()
+	}
+	if value {
+		return bitmaps[1]
+	}
+	return bitmaps[0]
+}
+
+// keywordValue returns the first keyword value of a field in a document.
+func (dv docValues) keywordValue(field string, docID int) (string, bool) {
+	values := dv.keywordValues(field, docID)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// keywordValues returns the keyword values of a field in a document.
+func (dv docValues) keywordValues(field string, docID int) []string {
+	if column := dv.keyword[field]; docID < len(column) {
+		return column[docID]
+	}
+	return nil
+}
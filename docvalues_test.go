@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateFieldWithCustomLayout(t *testing.T) {
+	docs := []Document{
+		{ID: "time", Fields: map[string]interface{}{"published": time.Date(2020, 3, 4, 12, 0, 0, 0, time.UTC)}},
+		{ID: "text", Fields: map[string]interface{}{"published": "05/03/2020"}},
+		{ID: "late", Fields: map[string]interface{}{"published": "05/03/2021"}},
+	}
+	se := NewSearchEngine(docs, Config{Fields: map[string]FieldOptions{
+		"published": {Type: FieldDate, DateLayouts: []string{"02/01/2006"}},
+	}})
+
+	q := DateRangeQuery{
+		Field: "published",
+		From:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	result := se.SearchQuery(q, SearchOptions{})
+	ids := make(map[string]bool)
+	for _, hit := range result.Hits {
+		ids[hit.ID] = true
+	}
+	if len(ids) != 2 || !ids["time"] || !ids["text"] {
+		t.Errorf("got hits %v, want time and text", hitIDs(result.Hits))
+	}
+}
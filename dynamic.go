@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"path"
+	"time"
+)
+
+var ErrUnknownField = errors.New("field not in the mapping")
+
+// DynamicMapping is how a SearchEngine maps a field missing from
+// Config.Fields and Config.DynamicRules the first time a document has it.
+type DynamicMapping int
+
+const (
+	// DynamicText indexes unknown fields as text, without adding them to
+	// the mapping.
+	DynamicText DynamicMapping = iota
+	// DynamicInfer adds unknown fields to the mapping with the type of
+	// their first value, or of its first element if it is a slice:
+	// FieldBool for a bool, FieldInt for a Go integer, FieldFloat for a Go
+	// float, FieldDate for a time.Time or a string in one of the
+	// DefaultDateLayouts, FieldGeo for a GeoPoint and FieldText for
+	// anything else. Later values of the field must fit that type.
+	DynamicInfer
+	// DynamicIgnore keeps unknown fields in the stored documents without
+	// indexing them.
+	DynamicIgnore
+	// DynamicStrict rejects documents with unknown fields, with an error
+	// wrapping ErrUnknownField.
+	DynamicStrict
+)
+
+// DynamicRule maps the unknown fields whose names match Match, a path.Match
+// pattern such as "*_at", with Options, whatever Config.Dynamic says.
+type DynamicRule struct {
+	Match   string
+	Options FieldOptions
+}
+
+// dynamicOptions returns the options of a field missing from the mapping
+// given its first value, and whether they are to be added to it.
+func (se *SearchEngine) dynamicOptions(name string, value interface{}) (FieldOptions, bool, error) {
+	for _, rule := range se.dynamicRules {
+		if ok, _ := path.Match(rule.Match, name); ok {
+			return rule.Options.withTypeDefaults(), true, nil
+		}
+	}
+	switch se.dynamic {
+	case DynamicInfer:
+		return FieldOptions{Type: inferType(value)}.withTypeDefaults(), true, nil
+	case DynamicIgnore:
+		return FieldOptions{StoreOnly: true}, true, nil
+	case DynamicStrict:
+		return FieldOptions{}, false, ErrUnknownField
+	}
+	return FieldOptions{}, false, nil
+}
+
+// inferType returns the type DynamicInfer gives a field with value.
+func inferType(value interface{}) FieldType {
+	if values, ok := sliceValues(value); ok {
+		if len(values) == 0 {
+			return FieldText
+		}
+		value = values[0]
+	}
+	switch v := value.(type) {
+	case bool:
+		return FieldBool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return FieldInt
+	case float32, float64:
+		return FieldFloat
+	case time.Time:
+		return FieldDate
+	case GeoPoint:
+		return FieldGeo
+	case string:
+		if _, ok := parseDate(v, nil); ok {
+			return FieldDate
+		}
+	}
+	return FieldText
+}
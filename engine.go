@@ -0,0 +1,1039 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config holds the settings of a SearchEngine. The zero value indexes every
+// field with the standard analyzer.
+type Config struct {
+	// Analyzer analyzes document fields, NewStandardAnalyzer() if nil.
+	Analyzer *Analyzer
+	// SearchAnalyzer analyzes queries, Analyzer if nil.
+	SearchAnalyzer *Analyzer
+	// Fields declares the types of individual fields and overrides their
+	// analysis.
+	Fields map[string]FieldOptions
+	// Dynamic is how fields missing from Fields are mapped when they first
+	// show up, DynamicText by default.
+	Dynamic DynamicMapping
+	// DynamicRules map the fields missing from Fields whose names they
+	// match, before Dynamic is applied. The first matching rule wins.
+	DynamicRules []DynamicRule
+	// DefaultField is the field searched by query clauses without a field
+	// prefix, every indexed field if empty.
+	DefaultField string
+	// Languages maps language codes to analyzers. When set, the language of
+	// each document is detected at index time and fields without an
+	// analyzer of their own are analyzed with the analyzer of that language.
+	Languages map[string]*Analyzer
+	// OmitPositions leaves token positions and offsets out of the index to
+	// save memory when no query needs them.
+	OmitPositions bool
+	// CompressPostings keeps posting lists delta and varint encoded in
+	// memory, trading some query time for a much smaller index.
+	CompressPostings bool
+	// BitmapThreshold, when positive, stores the posting lists of terms
+	// found in more than this fraction of the documents as roaring bitmaps,
+	// which are faster to intersect and combine in boolean queries.
+	BitmapThreshold float64
+	// FlushThreshold is the number of documents buffered in memory before
+	// they are written to a new segment, 1000 if zero.
+	FlushThreshold int
+	// MergeFactor is the number of segments that triggers a background
+	// merge, 10 if less than two.
+	MergeFactor int
+	// Rewrite, if set, is applied to every query tree before it runs, to
+	// add filters, expand terms and the like. RewriteQuery helps it reach
+	// the nested clauses.
+	Rewrite func(Query) Query
+	// Scorer scores the terms of queries, TFIDF{} if nil.
+	Scorer Scorer
+	// Coord multiplies the scores of the queries given to Search and
+	// SearchField by the fraction of their clauses and terms a document
+	// matches (see BooleanQuery.Coord).
+	Coord bool
+	// TieBreak is the field that orders results with equal scores, by its
+	// doc values if it has some and by its text otherwise. Remaining ties
+	// are ordered by document ID, so results always come in the same
+	// order.
+	TieBreak string
+}
+
+var (
+	ErrDocumentExists   = errors.New("document already exists")
+	ErrDocumentNotFound = errors.New("document not found")
+)
+
+const (
+	defaultFlushThreshold = 1000
+	defaultMergeFactor    = 10
+)
+
+// SearchEngine indexes documents into segments: new documents go to an
+// in-memory buffer, which is flushed into an immutable segment once it holds
+// FlushThreshold documents. Whenever MergeFactor segments accumulate, the
+// smallest of them are merged into one in the background. Deleted documents
+// are marked in a tombstone bitmap and dropped when their segment is merged.
+//
+// Postings refer to documents by an internal ID, their index in documents,
+// which stays fixed for the life of the engine. Updating a document deletes
+// the old version and indexes the new one under a new internal ID; ids maps
+// every Document.ID to the internal ID of its current version.
+//
+// A SearchEngine is safe for concurrent use. Searches run in parallel with
+// each other, while adding, updating or removing a document waits for
+// running searches and blocks new ones until it is done, so a search sees
+// either all or none of a write. Flushes and merges only swap segments and
+// never block searches for long.
+type SearchEngine struct {
+	// rw guards the documents, the ID map, the buffer contents and the
+	// statistics: writes hold it exclusively, searches hold it shared.
+	rw        sync.RWMutex
+	documents []Document
+	ids       map[string]int
+	// shared is set while a snapshot shares documents and ids, which the
+	// next write then copies.
+	shared         int32
+	analyzer       *Analyzer
+	searchAnalyzer *Analyzer
+	// fields is the mapping. Fields added to it dynamically replace the
+	// whole map, so a map once read under rw stays valid without it.
+	fields         map[string]FieldOptions
+	dynamic        DynamicMapping
+	dynamicRules   []DynamicRule
+	defaultField   string
+	languages      map[string]*Analyzer
+	rewrite        func(Query) Query
+	tieBreak       string
+	coord          bool
+	indexOptions   IndexOptions
+	flushThreshold int
+	mergeFactor    int
+
+	// mu guards the segment list, the tombstones and the doc values against
+	// flushes and background merges, which do not take rw.
+	mu       sync.Mutex
+	segments []*segment
+	buffer   *segment
+	deleted  *Bitmap
+	merges   sync.WaitGroup
+	merging  bool
+
+	numLive     int
+	fieldLength map[string]float64
+	// docLength holds the number of tokens of every field of every
+	// document, indexed by internal ID, so scoring need not re-analyze the
+	// documents.
+	docLength map[string][]int
+	// docNorm holds the Euclidean norm of the term frequencies of every
+	// field of every document, for cosine similarity.
+	docNorm map[string][]float64
+	// fingerprints holds the SimHash of the terms of every document,
+	// indexed by internal ID, to find near-duplicates.
+	fingerprints []uint64
+	docValues    docValues
+	scorer       Scorer
+
+	filterMu    sync.Mutex
+	filterCache map[string]*Bitmap
+
+	savedMu sync.RWMutex
+	saved   map[string]savedQuery
+}
+
+// NewSearchEngine indexes every field of the documents. A document
+// repeating an earlier ID replaces it; documents that do not fit the
+// mapping are skipped, as ValidateDocument tells.
+func NewSearchEngine(documents []Document, config Config) *SearchEngine {
+	se := newSearchEngine(config)
+	for _, doc := range documents {
+		se.putDocument(doc)
+	}
+	se.Flush()
+	return se
+}
+
+// newSearchEngine returns an empty SearchEngine.
+func newSearchEngine(config Config) *SearchEngine {
+	se := &SearchEngine{
+		ids:            make(map[string]int),
+		analyzer:       config.Analyzer,
+		searchAnalyzer: config.SearchAnalyzer,
+		fields:         make(map[string]FieldOptions, len(config.Fields)),
+		dynamic:        config.Dynamic,
+		dynamicRules:   config.DynamicRules,
+		defaultField:   config.DefaultField,
+		languages:      config.Languages,
+		rewrite:        config.Rewrite,
+		tieBreak:       config.TieBreak,
+		coord:          config.Coord,
+		indexOptions: IndexOptions{
+			Positions:       !config.OmitPositions,
+			Compress:        config.CompressPostings,
+			BitmapThreshold: config.BitmapThreshold,
+		},
+		flushThreshold: config.FlushThreshold,
+		mergeFactor:    config.MergeFactor,
+		buffer:         newSegment(),
+		deleted:        NewBitmap(),
+		fieldLength:    make(map[string]float64),
+		docLength:      make(map[string][]int),
+		docNorm:        make(map[string][]float64),
+		docValues:      newDocValues(),
+		scorer:         config.Scorer,
+	}
+	if se.analyzer == nil {
+		se.analyzer = NewStandardAnalyzer()
+	}
+	if se.searchAnalyzer == nil {
+		se.searchAnalyzer = se.analyzer
+	}
+	if se.scorer == nil {
+		se.scorer = TFIDF{}
+	}
+	if se.flushThreshold <= 0 {
+		se.flushThreshold = defaultFlushThreshold
+	}
+	if se.mergeFactor < 2 {
+		se.mergeFactor = defaultMergeFactor
+	}
+	for field, options := range config.Fields {
+		se.fields[field] = options.withTypeDefaults()
+	}
+	return se
+}
+
+// AddDocument indexes a new document. It is searchable right away.
+func (se *SearchEngine) AddDocument(doc Document) error {
+	se.rw.Lock()
+	defer se.rw.Unlock()
+	if _, ok := se.ids[doc.ID]; ok {
+		return fmt.Errorf("%w: %q", ErrDocumentExists, doc.ID)
+	}
+	return se.putDocument(doc)
+}
+
+// UpdateDocument replaces the document with the same ID.
+func (se *SearchEngine) UpdateDocument(doc Document) error {
+	se.rw.Lock()
+	defer se.rw.Unlock()
+	if _, ok := se.ids[doc.ID]; !ok {
+		return fmt.Errorf("%w: %q", ErrDocumentNotFound, doc.ID)
+	}
+	return se.putDocument(doc)
+}
+
+// RemoveDocument deletes the document with the given ID.
+func (se *SearchEngine) RemoveDocument(id string) error {
+	se.rw.Lock()
+	defer se.rw.Unlock()
+	docID, ok := se.ids[id]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrDocumentNotFound, id)
+	}
+	se.unshare()
+	delete(se.ids, id)
+	se.deleteDocument(docID)
+	return nil
+}
+
+// putDocument maps the new fields of a document and indexes it, unless it
+// does not fit the mapping. se.rw must be held for writing.
+func (se *SearchEngine) putDocument(doc Document) error {
+	doc.Fields = flattenFields(doc.Fields)
+	added, err := se.checkDocument(doc)
+	if err != nil {
+		return err
+	}
+	se.addFields(added)
+	se.indexDocument(doc)
+	return nil
+}
+
+// indexDocument analyzes a document into the buffer under a new internal
+// ID, deleting the previous version of the document if there is one. se.rw
+// must be held for writing.
+func (se *SearchEngine) indexDocument(doc Document) {
+	se.unshare()
+	if docID, ok := se.ids[doc.ID]; ok {
+		se.deleteDocument(docID)
+	}
+	if len(se.languages) > 0 && doc.Language == "" {
+		candidates := make([]string, 0, len(se.languages))
+		for language := range se.languages {
+			candidates = append(candidates, language)
+		}
+		doc.Language = DetectLanguage(doc.text(), candidates)
+	}
+	docID := len(se.documents)
+	se.clearFilterCache()
+
+	fields := make(map[string][]Token)
+	for _, field := range documentFields(doc) {
+		if !se.isIndexed(field) {
+			continue
+		}
+		fields[field] = analyzeValues(se.indexAnalyzer(doc, field), doc.FieldValues(field))
+		se.fieldLength[field] += float64(len(fields[field]))
+		se.setDocLength(field, docID, len(fields[field]))
+		se.setDocNorm(field, docID, termFreqNorm(fields[field]))
+	}
+	se.numLive++
+	se.fingerprints = append(se.fingerprints, simHash(fields))
+	se.documents = append(se.documents, se.storedDocument(doc))
+	se.ids[doc.ID] = docID
+
+	se.mu.Lock()
+	for _, field := range documentFields(doc) {
+		if options := se.fields[field]; options.Type == FieldGeo {
+			values := fieldValues(doc.Fields[field])
+			if len(values) == 0 {
+				continue
+			}
+			p, _ := geoPoint(values[0])
+			se.docValues.setNumeric(field+".lat", docID, p.Lat)
+			se.docValues.setNumeric(field+".lon", docID, p.Lon)
+		} else if options.DocValues != NoDocValues {
+			se.docValues.add(docID, field, fieldValues(doc.Fields[field]), options)
+		}
+	}
+	se.buffer.add(docID, fields, se.indexOptions.Positions)
+	full := se.buffer.numDocs() >= se.flushThreshold
+	se.mu.Unlock()
+	if full {
+		se.Flush()
+	}
+}
+
+// unshare copies the document table and the ID map if a snapshot shares
+// them. se.rw must be held for writing.
+func (se *SearchEngine) unshare() {
+	if atomic.LoadInt32(&se.shared) == 0 {
+		return
+	}
+	se.documents = append([]Document(nil), se.documents...)
+	ids := make(map[string]int, len(se.ids))
+	for id, docID := range se.ids {
+		ids[id] = docID
+	}
+	se.ids = ids
+	atomic.StoreInt32(&se.shared, 0)
+}
+
+// deleteDocument marks a document as deleted. Its postings stay in the
+// segments until they are merged.
+func (se *SearchEngine) deleteDocument(docID int) {
+	if se.isDeleted(docID) {
+		return
+	}
+	se.mu.Lock()
+	se.deleted.Add(uint32(docID))
+	se.mu.Unlock()
+	se.clearFilterCache()
+
+	for field := range se.docLength {
+		se.fieldLength[field] -= float64(se.fieldDocLength(field, docID))
+	}
+	se.numLive--
+	// Only the ID is needed from a deleted document from now on.
+	se.documents[docID] = Document{ID: se.documents[docID].ID}
+}
+
+func (se *SearchEngine) setDocLength(field string, docID, length int) {
+	lengths := se.docLength[field]
+	for len(lengths) <= docID {
+		lengths = append(lengths, 0)
+	}
+	lengths[docID] = length
+	se.docLength[field] = lengths
+}
+
+func (se *SearchEngine) setDocNorm(field string, docID int, norm float64) {
+	norms := se.docNorm[field]
+	for len(norms) <= docID {
+		norms = append(norms, 0)
+	}
+	norms[docID] = norm
+	se.docNorm[field] = norms
+}
+
+// fieldDocNorm returns the norm of the term frequencies of a field in a
+// document.
+func (se *SearchEngine) fieldDocNorm(field string, docID int) float64 {
+	if norms := se.docNorm[field]; docID < len(norms) {
+		return norms[docID]
+	}
+	return 0
+}
+
+// termFreqNorm returns the Euclidean norm of the term frequencies of
+// tokens.
+func termFreqNorm(tokens []Token) float64 {
+	freqs := make(map[string]int)
+	for _, token := range tokens {
+		freqs[token.Term]++
+	}
+	sum := 0
+	for _, freq := range freqs {
+		sum += freq * freq
+	}
+	return math.Sqrt(float64(sum))
+}
+
+// fieldDocLength returns the number of tokens of a field in a document.
+func (se *SearchEngine) fieldDocLength(field string, docID int) int {
+	if lengths := se.docLength[field]; docID < len(lengths) {
+		return lengths[docID]
+	}
+	return 0
+}
+
+func (se *SearchEngine) isDeleted(docID int) bool {
+	return se.deleted.Contains(uint32(docID))
+}
+
+func (se *SearchEngine) isIndexed(field string) bool {
+	return !se.fields[field].StoreOnly
+}
+
+func (se *SearchEngine) isStored(field string) bool {
+	return !se.fields[field].IndexOnly
+}
+
+// storedDocument returns the part of a document kept in memory, without
+// its index-only fields.
+func (se *SearchEngine) storedDocument(doc Document) Document {
+	var fields map[string]interface{}
+	for name, value := range doc.Fields {
+		if !se.isStored(name) {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]interface{})
+		}
+		fields[name] = value
+	}
+	doc.Fields = fields
+	return doc
+}
+
+// Flush turns the buffered documents into a new immutable segment.
+func (se *SearchEngine) Flush() {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	if se.buffer.numDocs() == 0 {
+		return
+	}
+	se.segments = append(se.segments, se.buffer.freeze(se.docValues.clone(), se.indexOptions))
+	se.buffer = newSegment()
+	se.maybeMerge()
+}
+
+// maybeMerge starts merging the mergeFactor smallest segments in the
+// background once that many have accumulated. se.mu must be held.
+func (se *SearchEngine) maybeMerge() {
+	if se.merging || len(se.segments) < se.mergeFactor {
+		return
+	}
+	candidates := append([]*segment(nil), se.segments...)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].numDocs() < candidates[j].numDocs()
+	})
+	candidates = candidates[:se.mergeFactor]
+	deleted := se.deleted.Or(NewBitmap())
+	values := se.docValues.clone()
+
+	se.merging = true
+	se.merges.Add(1)
+	go func() {
+		defer se.merges.Done()
+		merged := mergeSegments(candidates, deleted, values, se.indexOptions)
+
+		se.mu.Lock()
+		defer se.mu.Unlock()
+		se.replaceSegments(candidates, merged)
+		se.merging = false
+		se.maybeMerge()
+	}()
+}
+
+// replaceSegments swaps old segments for their merged replacement. se.mu
+// must be held.
+func (se *SearchEngine) replaceSegments(old []*segment, merged *segment) {
+	replaced := make(map[*segment]bool, len(old))
+	for _, s := range old {
+		replaced[s] = true
+	}
+	segments := make([]*segment, 0, len(se.segments)-len(old)+1)
+	for _, s := range se.segments {
+		if !replaced[s] {
+			segments = append(segments, s)
+		}
+	}
+	se.segments = append(segments, merged)
+}
+
+// ForceMerge flushes the buffer and merges all segments into one, removing
+// deleted documents from the index for good.
+func (se *SearchEngine) ForceMerge() {
+	se.Flush()
+	se.merges.Wait()
+
+	se.mu.Lock()
+	segments := append([]*segment(nil), se.segments...)
+	deleted := se.deleted.Or(NewBitmap())
+	values := se.docValues.clone()
+	se.mu.Unlock()
+	if len(segments) == 0 {
+		return
+	}
+	merged := mergeSegments(segments, deleted, values, se.indexOptions)
+
+	se.mu.Lock()
+	se.replaceSegments(segments, merged)
+	se.mu.Unlock()
+}
+
+// searchableSegments returns the segments followed by the buffer.
+func (se *SearchEngine) searchableSegments() []*segment {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	segments := make([]*segment, 0, len(se.segments)+1)
+	segments = append(segments, se.segments...)
+	return append(segments, se.buffer)
+}
+
+// maxDoc counts the documents in the segments, including deleted ones that
+// are not merged away yet; like docFreq it is a corpus statistic for scoring.
+func maxDoc(segments []*segment) int {
+	n := 0
+	for _, s := range segments {
+		n += s.numDocs()
+	}
+	return n
+}
+
+// termPostings returns the posting lists of a term in every segment.
+func termPostings(segments []*segment, field, term string) []PostingList {
+	var lists []PostingList
+	for _, s := range segments {
+		if postings, ok := s.postings(field, term); ok {
+			lists = append(lists, postings)
+		}
+	}
+	return lists
+}
+
+func docFreq(lists []PostingList) int {
+	df := 0
+	for _, postings := range lists {
+		df += postings.Len()
+	}
+	return df
+}
+
+// indexedFields returns the names of all fields seen so far.
+func (se *SearchEngine) indexedFields() []string {
+	fields := make([]string, 0, len(se.fieldLength))
+	for field := range se.fieldLength {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (se *SearchEngine) avgFieldLength(field string) float64 {
+	return se.fieldLength[field] / float64(se.numLive)
+}
+
+// languageAnalyzed reports whether the field is analyzed with the detected
+// language of each document.
+func (se *SearchEngine) languageAnalyzed(field string) bool {
+	return se.fields[field].Analyzer == nil && len(se.languages) > 0
+}
+
+func (se *SearchEngine) indexAnalyzer(doc Document, field string) *Analyzer {
+	if options, ok := se.fields[field]; ok && options.Analyzer != nil {
+		return options.Analyzer
+	}
+	if analyzer, ok := se.languages[doc.Language]; ok && se.languageAnalyzed(field) {
+		return analyzer
+	}
+	return se.analyzer
+}
+
+func (se *SearchEngine) queryAnalyzer(field string) *Analyzer {
+	if options, ok := se.fields[field]; ok {
+		if options.SearchAnalyzer != nil {
+			return options.SearchAnalyzer
+		}
+		if options.Analyzer != nil {
+			return options.Analyzer
+		}
+	}
+	return se.searchAnalyzer
+}
+
+func (se *SearchEngine) CalculateTFIDFScore(field string, tokens []string) map[int]float64 {
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+	return se.termScores(field, tokens, TFIDF{})
+}
+
+func (se *SearchEngine) CalculateBM25Score(field string, tokens []string) map[int]float64 {
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+	return se.termScores(field, tokens, NewBM25())
+}
+
+// defaultSize is the number of results of a search without a Size.
+const defaultSize = 10
+
+// SearchOptions selects the page of results a search returns.
+type SearchOptions struct {
+	// Size is the number of results, 10 if zero.
+	Size int
+	// From is the number of best results to skip.
+	From int
+	// After, if set, skips the results up to and including the one it
+	// points at, usually the last hit of the previous page. Unlike From,
+	// it costs no more on the hundredth page than on the first, and pages
+	// do not shift when documents are added.
+	After *Cursor
+	// Highlight, if set, marks the matched terms in the text of the hits.
+	Highlight *HighlightOptions
+	// Facets are KeywordDocValues fields to count the values of over every
+	// matching document, keeping the FacetSize most frequent (10 if zero).
+	Facets    []string
+	FacetSize int
+	// Stats are NumericDocValues or DateDocValues fields to compute the
+	// statistics of over every matching document.
+	Stats []string
+	// Histograms bucket the matching documents by numeric or date field.
+	Histograms []Histogram
+	// SignificantTerms, if set, finds the terms that are unusually frequent
+	// in the matching documents.
+	SignificantTerms *SignificantTerms
+	// Sort orders the results by fields, then by score, instead of by
+	// score alone.
+	Sort []SortField
+	// Collapse, if set, keeps only the best document of each value of a
+	// field.
+	Collapse *Collapse
+	// Dedup, if set, keeps only the best of the near-duplicate documents.
+	Dedup *Dedup
+	// Normalize, if set, maps the scores of the hits and MaxScore, e.g.
+	// to [0, 1] with MaxNormalize or Sigmoid, so they compare across
+	// queries.
+	Normalize Normalizer
+	// SpellCheck, if set, suggests a correction of the rare terms of the
+	// query.
+	SpellCheck *SpellCheck
+}
+
+// Cursor is the position of a hit in the results of a search.
+// SearchResult.Next also records the values of the hit on the sort and
+// tie-break fields, so the cursor keeps its place when its document is
+// deleted or stops matching; a Cursor made of a Score and ID alone only
+// does without sort fields and tie-break.
+type Cursor struct {
+	Score float64
+	ID    string
+	keys  []sortKey
+}
+
+// SearchResult is a page of results.
+type SearchResult struct {
+	// Hits are the documents of the page, best first.
+	Hits []Hit
+	// Total is the number of documents matching the query.
+	Total int
+	// MaxScore is the best score of all the matching documents.
+	MaxScore float64
+	// Facets holds the value counts of the fields of SearchOptions.Facets.
+	Facets map[string][]FacetCount
+	// Stats holds the statistics of the fields of SearchOptions.Stats.
+	Stats map[string]NumericStats
+	// Histograms holds the buckets of SearchOptions.Histograms by field.
+	Histograms map[string][]Bucket
+	// SignificantTerms holds the terms found by
+	// SearchOptions.SignificantTerms, most significant first.
+	SignificantTerms []SignificantTerm
+	// Suggestion is the query with its rare terms corrected, when
+	// SearchOptions.SpellCheck is set and there are some.
+	Suggestion string
+	// Corrected reports whether the hits are those of Suggestion, the
+	// query having matched nothing.
+	Corrected bool
+	// Took is how long the search took.
+	Took time.Duration
+}
+
+// Hit is a document in the results of a search, with its Score set.
+type Hit struct {
+	Document
+	// MatchedTerms are the terms of the query the document contains, and
+	// MatchedFields the fields it contains them in.
+	MatchedTerms  []string
+	MatchedFields []string
+	// Highlights holds the text of the fields the query matched, or
+	// snippets of it, with the matched terms marked, when
+	// SearchOptions.Highlight is set.
+	Highlights map[string][]string
+	// InnerHits are the best documents of the group of the hit, itself
+	// included, when SearchOptions.Collapse asks for them.
+	InnerHits []Hit
+	// rawScore is the score before SearchOptions.Normalize and keys the
+	// sort keys of the hit, for cursors.
+	rawScore float64
+	keys     []sortKey
+}
+
+// Next returns the options for the page after r, searched with opts, or
+// nil if r has no hits.
+func (r SearchResult) Next(opts SearchOptions) *SearchOptions {
+	if len(r.Hits) == 0 {
+		return nil
+	}
+	last := r.Hits[len(r.Hits)-1]
+	opts.From = 0
+	opts.After = &Cursor{Score: last.rawScore, ID: last.ID, keys: last.keys}
+	return &opts
+}
+
+// Search runs a query in the syntax of ParseQuery. A query that does not
+// parse is searched as plain text.
+func (se *SearchEngine) Search(query string, opts SearchOptions) SearchResult {
+	return se.searchQuery(query, se.defaultField, opts)
+}
+
+// SearchField runs a query against a single field, e.g. an autocomplete
+// field while the user is typing.
+func (se *SearchEngine) SearchField(field, query string, opts SearchOptions) SearchResult {
+	return se.searchQuery(query, field, opts)
+}
+
+// SearchWithScorer is Search with the terms of the query scored by scorer
+// instead of the scorer of the index, e.g. to compare BM25 parameters on
+// the same index:
+//
+//	a := se.SearchWithScorer(query, BM25{K1: 1.2, B: 0.75}, opts)
+//	b := se.SearchWithScorer(query, BM25{K1: 2, B: 0.3}, opts)
+func (se *SearchEngine) SearchWithScorer(query string, scorer Scorer, opts SearchOptions) SearchResult {
+	return se.SearchQuery(WithScorer(se.parseSearch(query, se.defaultField), scorer), opts)
+}
+
+func (se *SearchEngine) searchQuery(query, field string, opts SearchOptions) SearchResult {
+	return se.SearchQuery(se.parseSearch(query, field), opts)
+}
+
+// parseSearch parses a query on field, falling back to matching its text
+// if it does not parse.
+func (se *SearchEngine) parseSearch(query, field string) Query {
+	q, err := parseQuery(query, field, se.mapping())
+	if err != nil {
+		q = MatchQuery{Field: field, Text: query}
+	}
+	if se.coord {
+		q = RewriteQuery(q, func(q Query) Query {
+			switch q := q.(type) {
+			case BooleanQuery:
+				q.Coord = true
+				return q
+			case MatchQuery:
+				q.Coord = true
+				return q
+			}
+			return q
+		})
+	}
+	return q
+}
+
+// SearchQuery runs a query tree, after Config.Rewrite if set.
+func (se *SearchEngine) SearchQuery(q Query, opts SearchOptions) SearchResult {
+	start := time.Now()
+	if se.rewrite != nil {
+		q = se.rewrite(q)
+	}
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+	scores := se.boostDocuments(q.score(se))
+	var result SearchResult
+	if opts.SpellCheck != nil {
+		if corrected, ok := se.correct(q, *opts.SpellCheck); ok {
+			result.Suggestion = corrected.String()
+			if opts.SpellCheck.AutoCorrect && len(scores) == 0 {
+				q, scores = corrected, se.boostDocuments(corrected.score(se))
+				result.Corrected = true
+			}
+		}
+	}
+	result.Total = len(scores)
+	first := true
+	for _, score := range scores {
+		if first || score > result.MaxScore {
+			result.MaxScore, first = score, false
+		}
+	}
+	if len(opts.Facets) > 0 {
+		result.Facets = se.facets(scores, opts.Facets, opts.FacetSize)
+	}
+	if len(opts.Stats) > 0 {
+		result.Stats = se.numericStats(scores, opts.Stats)
+	}
+	if len(opts.Histograms) > 0 {
+		result.Histograms = se.histograms(scores, opts.Histograms)
+	}
+	if opts.SignificantTerms != nil {
+		result.SignificantTerms = se.significantTerms(scores, *opts.SignificantTerms)
+	}
+	better := se.ranking(scores, opts.Sort)
+	hits := scores
+	if opts.Dedup != nil {
+		hits = se.dedup(hits, opts.Dedup.Distance, better)
+	}
+	var groups map[string][]int
+	if opts.Collapse != nil {
+		hits, groups = se.collapse(hits, opts.Collapse.Field, better)
+	}
+	if opts.After != nil {
+		se.skipThrough(hits, *opts.After, opts.Sort, better)
+	}
+	result.Hits = se.topDocuments(hits, opts, better)
+	terms := se.queryTerms(q)
+	for i := range result.Hits {
+		hit := &result.Hits[i]
+		docID := se.ids[hit.ID]
+		hit.MatchedTerms, hit.MatchedFields = se.matchedTerms(docID, terms)
+		if opts.Highlight != nil {
+			hit.Highlights = se.highlight(hit.Document, terms, *opts.Highlight)
+		}
+		if opts.Collapse != nil && opts.Collapse.InnerHits > 0 {
+			hit.InnerHits = se.innerHits(scores, groups[se.sortText(opts.Collapse.Field, docID)], docID, opts.Collapse.InnerHits, better)
+		}
+	}
+	if opts.Normalize != nil {
+		result.normalize(opts.Normalize)
+	}
+	result.Took = time.Since(start)
+	return result
+}
+
+// matchedTerms returns the distinct terms of terms that docID contains and
+// the distinct fields it contains them in, in the order of terms.
+func (se *SearchEngine) matchedTerms(docID int, terms []fieldTerm) (matchedTerms, matchedFields []string) {
+	seenTerms := make(map[string]bool)
+	seenFields := make(map[string]bool)
+	segments := se.searchableSegments()
+	for _, t := range terms {
+		if seenTerms[t.term] && seenFields[t.field] {
+			continue
+		}
+		for _, postings := range termPostings(segments, t.field, t.term) {
+			if it := postings.Iterator(); it.Advance(docID) && it.Posting().DocID == docID {
+				if !seenTerms[t.term] {
+					seenTerms[t.term] = true
+					matchedTerms = append(matchedTerms, t.term)
+				}
+				if !seenFields[t.field] {
+					seenFields[t.field] = true
+					matchedFields = append(matchedFields, t.field)
+				}
+				break
+			}
+		}
+	}
+	return matchedTerms, matchedFields
+}
+
+// skipThrough removes the documents ranking up to and including after
+// from scores, in the order of better. A cursor with sort keys is placed
+// by them; one without is placed by its document while that is still in
+// the results, and otherwise by its score and ID.
+func (se *SearchEngine) skipThrough(scores map[int]float64, after Cursor, sortFields []SortField, better func(a, b int) bool) {
+	n := len(sortFields)
+	if se.tieBreak != "" {
+		n++
+	}
+	if after.keys != nil && len(after.keys) == n {
+		for docID, score := range scores {
+			if !se.follows(docID, score, after, sortFields) {
+				delete(scores, docID)
+			}
+		}
+		return
+	}
+	afterID, found := se.ids[after.ID]
+	if _, ok := scores[afterID]; found && ok {
+		// better reads the score of after, so it goes last.
+		for docID := range scores {
+			if docID != afterID && better(docID, afterID) {
+				delete(scores, docID)
+			}
+		}
+		delete(scores, afterID)
+		return
+	}
+	for docID, score := range scores {
+		switch {
+		case score > after.Score:
+			delete(scores, docID)
+		case score < after.Score:
+		case se.documents[docID].ID <= after.ID:
+			delete(scores, docID)
+		}
+	}
+}
+
+// follows reports whether a document with score ranks after the cursor, in
+// the order of ranking and breakTie.
+func (se *SearchEngine) follows(docID int, score float64, after Cursor, sortFields []SortField) bool {
+	for i, s := range sortFields {
+		if c := compareSortKeys(se.sortKey(s.Field, docID), after.keys[i], s.Desc); c != 0 {
+			return c > 0
+		}
+	}
+	if score != after.Score {
+		return score < after.Score
+	}
+	if se.tieBreak != "" {
+		if c := compareSortKeys(se.sortKey(se.tieBreak, docID), after.keys[len(sortFields)], false); c != 0 {
+			return c > 0
+		}
+	}
+	return se.documents[docID].ID > after.ID
+}
+
+// boostDocuments multiplies scores by the boosts of the documents.
+func (se *SearchEngine) boostDocuments(scores map[int]float64) map[int]float64 {
+	for docID := range scores {
+		if boost := se.documents[docID].Boost; boost != 0 {
+			scores[docID] *= boost
+		}
+	}
+	return scores
+}
+
+func (se *SearchEngine) scoreField(field, query string, scorer Scorer) map[int]float64 {
+	return se.scoreAnalyzed(field, query, func(tokens []Token) map[int]float64 {
+		terms := make([]string, len(tokens))
+		for i, token := range tokens {
+			terms[i] = token.Term
+		}
+		return se.scoreTokens(field, terms, scorer)
+	})
+}
+
+// scoreAnalyzed analyzes the query for the field and scores it with score.
+func (se *SearchEngine) scoreAnalyzed(field, query string, score func(tokens []Token) map[int]float64) map[int]float64 {
+	if !se.languageAnalyzed(field) {
+		return score(se.queryAnalyzer(field).Analyze(query))
+	}
+	// A document only matches the query analyzed the way the document was.
+	scores := make(map[int]float64)
+	for docID, s := range score(se.searchAnalyzer.Analyze(query)) {
+		if _, ok := se.languages[se.documents[docID].Language]; !ok {
+			scores[docID] = s
+		}
+	}
+	for language, analyzer := range se.languages {
+		for docID, s := range score(analyzer.Analyze(query)) {
+			if se.documents[docID].Language == language {
+				scores[docID] = s
+			}
+		}
+	}
+	return scores
+}
+
+// scoreTokens scores tokens with scorer, or the scorer of the index if nil.
+func (se *SearchEngine) scoreTokens(field string, tokens []string, scorer Scorer) map[int]float64 {
+	if scorer == nil {
+		scorer = se.scorer
+	}
+	return se.termScores(field, tokens, scorer)
+}
+
+// topDocuments returns the page of the best documents in the order of
+// better selected by opts.
+func (se *SearchEngine) topDocuments(scores map[int]float64, opts SearchOptions, better func(a, b int) bool) []Hit {
+	size := opts.Size
+	if size <= 0 {
+		size = defaultSize
+	}
+	from := opts.From
+	if from < 0 {
+		from = 0
+	}
+	docIDs := topDocIDs(scores, from+size, better)
+	if from >= len(docIDs) {
+		return nil
+	}
+	var results []Hit
+	for _, docID := range docIDs[from:] {
+		doc := se.documents[docID]
+		doc.Score = scores[docID]
+		results = append(results, Hit{Document: doc, rawScore: doc.Score, keys: se.sortKeys(docID, opts.Sort)})
+	}
+	return results
+}
+
+// topDocIDs returns the n best documents of scores in the order of better,
+// best first. It keeps the best documents seen so far in a min-heap of
+// size n, so it takes O(len(scores) log n) time and O(n) space.
+func topDocIDs(scores map[int]float64, n int, better func(a, b int) bool) []int {
+	if n <= 0 {
+		return nil
+	}
+	h := &topHeap{better: better}
+	for docID := range scores {
+		if len(h.docIDs) < n {
+			heap.Push(h, docID)
+		} else if h.better(docID, h.docIDs[0]) {
+			h.docIDs[0] = docID
+			heap.Fix(h, 0)
+		}
+	}
+	docIDs := make([]int, len(h.docIDs))
+	for i := len(docIDs) - 1; i >= 0; i-- {
+		docIDs[i] = heap.Pop(h).(int)
+	}
+	return docIDs
+}
+
+// topHeap is a min-heap of documents, the worst at the top.
+type topHeap struct {
+	docIDs []int
+	better func(a, b int) bool
+}
+
+func (h *topHeap) Len() int           { return len(h.docIDs) }
+func (h *topHeap) Less(i, j int) bool { return h.better(h.docIDs[j], h.docIDs[i]) }
+func (h *topHeap) Swap(i, j int)      { h.docIDs[i], h.docIDs[j] = h.docIDs[j], h.docIDs[i] }
+func (h *topHeap) Push(x interface{}) { h.docIDs = append(h.docIDs, x.(int)) }
+func (h *topHeap) Pop() interface{} {
+	docID := h.docIDs[len(h.docIDs)-1]
+	h.docIDs = h.docIDs[:len(h.docIDs)-1]
+	return docID
+}
+
+// breakTie reports whether document a comes before document b when their
+// scores are equal: by the tie-break field, then by ID. Documents without
+// a value for the field come last.
+func (se *SearchEngine) breakTie(a, b int) bool {
+	if field := se.tieBreak; field != "" {
+		if c := se.compareField(field, false, a, b); c != 0 {
+			return c < 0
+		}
+	}
+	return se.documents[a].ID < se.documents[b].ID
+}
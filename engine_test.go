@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// newPagingEngine returns an engine with many documents sharing scores,
+// prices and titles, so that pages end in the middle of ties.
+func newPagingEngine() *SearchEngine {
+	var docs []Document
+	for i := 0; i < 30; i++ {
+		body := "fox dog"
+		if i%3 == 0 {
+			body = "fox fox"
+		}
+		docs = append(docs, Document{ID: fmt.Sprintf("doc%02d", i), Fields: map[string]interface{}{
+			"body":  body,
+			"price": i % 4,
+			"title": fmt.Sprintf("title%d", i%5),
+		}})
+	}
+	return NewSearchEngine(docs, Config{
+		Fields: map[string]FieldOptions{
+			"price": {Type: FieldInt},
+			"title": {Type: FieldKeyword},
+		},
+		TieBreak: "title",
+	})
+}
+
+func hitIDs(hits []Hit) []string {
+	var ids []string
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+// pageThrough collects the hits of every page of a search, calling
+// betweenPages after each page.
+func pageThrough(se *SearchEngine, opts SearchOptions, betweenPages func(SearchResult)) []string {
+	var ids []string
+	for page := &opts; page != nil; {
+		result := se.Search("fox", *page)
+		ids = append(ids, hitIDs(result.Hits)...)
+		if betweenPages != nil {
+			betweenPages(result)
+		}
+		page = result.Next(*page)
+		if len(ids) > 100 {
+			break // a cursor that does not advance
+		}
+	}
+	return ids
+}
+
+func TestCursorPagination(t *testing.T) {
+	se := newPagingEngine()
+	tests := []struct {
+		name string
+		sort []SortField
+	}{
+		{"by score", nil},
+		{"by price", []SortField{{Field: "price"}}},
+		{"by price descending", []SortField{{Field: "price", Desc: true}}},
+		{"by title and price", []SortField{{Field: "title"}, {Field: "price", Desc: true}}},
+	}
+	for _, tt := range tests {
+		want := hitIDs(se.Search("fox", SearchOptions{Size: 100, Sort: tt.sort}).Hits)
+		if len(want) != 30 {
+			t.Fatalf("%s: %d hits, want 30", tt.name, len(want))
+		}
+		for _, size := range []int{1, 4, 7, 30} {
+			got := pageThrough(se, SearchOptions{Size: size, Sort: tt.sort}, nil)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s, pages of %d: got %v, want %v", tt.name, size, got, want)
+			}
+		}
+	}
+}
+
+func TestCursorPaginationAfterDeletes(t *testing.T) {
+	for _, sortFields := range [][]SortField{nil, {{Field: "price", Desc: true}}} {
+		se := newPagingEngine()
+		all := hitIDs(se.Search("fox", SearchOptions{Size: 100, Sort: sortFields}).Hits)
+		deleted := make(map[string]bool)
+		// Deleting the last hit of every page leaves its cursor pointing
+		// at a document that is gone.
+		got := pageThrough(se, SearchOptions{Size: 4, Sort: sortFields}, func(result SearchResult) {
+			if len(result.Hits) == 0 {
+				return
+			}
+			id := result.Hits[len(result.Hits)-1].ID
+			if err := se.RemoveDocument(id); err != nil {
+				t.Fatal(err)
+			}
+			deleted[id] = true
+		})
+		if !reflect.DeepEqual(got, all) {
+			t.Errorf("sort %v: got %v, want %v", sortFields, got, all)
+		}
+		if len(deleted) == 0 {
+			t.Errorf("sort %v: nothing deleted", sortFields)
+		}
+	}
+}
+
+func TestNextWithoutHits(t *testing.T) {
+	se := newPagingEngine()
+	if next := se.Search("unicorn", SearchOptions{}).Next(SearchOptions{}); next != nil {
+		t.Errorf("Next of an empty result = %+v, want nil", next)
+	}
+	result := se.Search("fox", SearchOptions{Size: 30})
+	next := result.Next(SearchOptions{Size: 30, From: 5})
+	if next.From != 0 || next.After == nil || next.After.ID != result.Hits[29].ID {
+		t.Errorf("Next = %+v, want From 0 and a cursor at %s", next, result.Hits[29].ID)
+	}
+	if hits := se.Search("fox", *next).Hits; len(hits) != 0 {
+		t.Errorf("page after the last has %d hits", len(hits))
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Explanation is a breakdown of a score: how Value was computed from the
+// values of Details.
+type Explanation struct {
+	Value       float64
+	Description string
+	Details     []Explanation
+}
+
+// String returns the explanation as an indented tree.
+func (e Explanation) String() string {
+	var b strings.Builder
+	e.write(&b, 0)
+	return b.String()
+}
+
+func (e Explanation) write(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%s%.4f %s\n", strings.Repeat("  ", depth), e.Value, e.Description)
+	for _, detail := range e.Details {
+		detail.write(b, depth+1)
+	}
+}
+
+// ScoreExplainer is implemented by scorers that can break their scores
+// down. The scores of other scorers are explained by their statistics.
+type ScoreExplainer interface {
+	Explain(term TermStats, doc DocStats, field FieldStats) Explanation
+}
+
+func (s TFIDF) Explain(term TermStats, doc DocStats, field FieldStats) Explanation {
+	idf := math.Log(float64(field.DocCount) / float64(term.DocFreq))
+	e := Explanation{
+		Value:       s.Score(term, doc, field),
+		Description: "tf * idf",
+		Details: []Explanation{
+			{Value: float64(doc.Freq), Description: "tf, occurrences of the term in the field"},
+			{Value: idf, Description: fmt.Sprintf("idf, ln(N / df) with N = %d documents, df = %d", field.DocCount, term.DocFreq)},
+		},
+	}
+	if s.Slope != 0 {
+		e.Description = "tf * idf / norm"
+		e.Details = append(e.Details, Explanation{
+			Value:       s.norm(doc, field),
+			Description: fmt.Sprintf("norm, 1 - slope + slope * dl / avgdl with slope = %g, dl = %d, avgdl = %.2f", s.Slope, doc.Length, field.AvgLength),
+		})
+	}
+	return e
+}
+
+func (s BM25) Explain(term TermStats, doc DocStats, field FieldStats) Explanation {
+	df := float64(term.DocFreq)
+	idf := math.Log(1 + (float64(field.DocCount)-df+0.5)/(df+0.5))
+	norm := 1 - s.B
+	if field.AvgLength > 0 {
+		norm += s.B * float64(doc.Length) / field.AvgLength
+	}
+	tf := float64(doc.Freq)
+	return Explanation{
+		Value:       s.Score(term, doc, field),
+		Description: "idf * tf * (k1 + 1) / (tf + k1 * norm)",
+		Details: []Explanation{
+			{Value: idf, Description: fmt.Sprintf("idf, ln(1 + (N - df + 0.5) / (df + 0.5)) with N = %d documents, df = %d", field.DocCount, term.DocFreq)},
+			{Value: tf, Description: "tf, occurrences of the term in the field"},
+			{Value: s.K1, Description: "k1"},
+			{Value: norm, Description: fmt.Sprintf("norm, 1 - b + b * dl / avgdl with b = %g, dl = %d, avgdl = %.2f", s.B, doc.Length, field.AvgLength)},
+		},
+	}
+}
+
+// Explain explains the score of the document with the given ID for a query
+// in the syntax of ParseQuery.
+func (se *SearchEngine) Explain(query, id string) (Explanation, error) {
+	return se.ExplainQuery(se.parseSearch(query, se.defaultField), id)
+}
+
+// ExplainQuery explains the score of the document with the given ID for a
+// query tree, after Config.Rewrite if set. A document that does not match
+// has a zero explanation saying so.
+func (se *SearchEngine) ExplainQuery(q Query, id string) (Explanation, error) {
+	if se.rewrite != nil {
+		q = se.rewrite(q)
+	}
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+	docID, ok := se.ids[id]
+	if !ok || se.isDeleted(docID) {
+		return Explanation{}, fmt.Errorf("%w: %q", ErrDocumentNotFound, id)
+	}
+	e := se.explain(q, docID)
+	if boost := se.documents[docID].Boost; boost != 0 && e.Value != 0 {
+		e = Explanation{Value: e.Value * boost, Description: fmt.Sprintf("document boost ^%g of", boost), Details: []Explanation{e}}
+	}
+	return e, nil
+}
+
+// explain explains the score of docID for q. Every value comes from
+// scoring the query, so it matches the search exactly; the details break
+// it down as far as the query type allows.
+func (se *SearchEngine) explain(q Query, docID int) Explanation {
+	value, ok := q.score(se)[docID]
+	if !ok {
+		return Explanation{Description: "no match for " + q.String()}
+	}
+	e := Explanation{Value: value, Description: q.String()}
+	switch q := q.(type) {
+	case BooleanQuery:
+		e.Description = "sum of the matching clauses of " + q.String()
+		for _, clauses := range [][]Query{q.Must, q.Should} {
+			for _, clause := range clauses {
+				if detail := se.explain(clause, docID); detail.Value != 0 {
+					e.Details = append(e.Details, detail)
+				}
+			}
+		}
+		for _, clause := range q.Filter {
+			e.Details = append(e.Details, Explanation{Description: "filter " + clause.String()})
+		}
+	case BoostQuery:
+		e.Description = fmt.Sprintf("boost ^%g of", q.Boost)
+		e.Details = []Explanation{se.explain(q.Query, docID)}
+	case RecencyQuery:
+		e.Description = "recency decay, half-life " + q.HalfLife.String() + ", of"
+		e.Details = []Explanation{se.explain(q.Query, docID)}
+	case ScriptScoreQuery:
+		e.Description = "script " + q.Script.String() + " of"
+		e.Details = []Explanation{se.explain(q.Query, docID)}
+	case ProximityQuery:
+		e.Description = fmt.Sprintf("proximity boost ^%g of", q.Boost)
+		e.Details = []Explanation{se.explain(q.Query, docID)}
+	case DiversifyQuery:
+		e.Description = fmt.Sprintf("marginal relevance, lambda %g, of", q.Lambda)
+		e.Details = []Explanation{se.explain(q.Query, docID)}
+	case FunctionScoreQuery:
+		e.Description = "function score, " + q.BoostMode.String() + " of"
+		e.Details = []Explanation{se.explain(q.Query, docID)}
+		if value, ok := q.functionValue(se, docID); ok {
+			functions := Explanation{Value: value, Description: q.ScoreMode.String() + " of the functions"}
+			for _, fn := range q.Functions {
+				if v, ok := fn.value(se, docID); ok {
+					functions.Details = append(functions.Details, Explanation{Value: v, Description: fn.String()})
+				}
+			}
+			e.Details = append(e.Details, functions)
+		}
+	case MultiMatchQuery:
+		if q.Type == CombinedFields {
+			break
+		}
+		e.Description = "best field of " + q.String()
+		if q.Type == MostFields {
+			e.Description = "sum of the fields of " + q.String()
+		}
+		fields := q.Fields
+		if len(fields) == 0 {
+			fields = se.indexedFields()
+		}
+		for _, spec := range fields {
+			field, boost := parseFieldBoost(spec)
+			detail := se.explain(MatchQuery{Field: field, Text: q.Text, Scorer: q.Scorer}, docID)
+			if boost != 1 {
+				detail = Explanation{Value: detail.Value * boost, Description: fmt.Sprintf("boost ^%g of", boost), Details: []Explanation{detail}}
+			}
+			if detail.Value != 0 {
+				e.Details = append(e.Details, detail)
+			}
+		}
+	case MatchQuery:
+		e.Details = se.explainFields(q.Field, docID, q.Scorer, func(field string) []string {
+			var terms []string
+			for _, token := range se.explainAnalyzer(field, docID).Analyze(q.Text) {
+				terms = append(terms, token.Term)
+			}
+			return terms
+		})
+	case TermQuery:
+		e.Details = se.explainFields(q.Field, docID, q.Scorer, func(field string) []string {
+			return []string{q.Term}
+		})
+	case WildcardQuery:
+		e.Details = se.explainFields(q.Field, docID, q.Scorer, func(field string) []string {
+			return q.terms(se, field)
+		})
+	case FuzzyQuery:
+		e.Details = se.explainFields(q.Field, docID, q.Scorer, func(field string) []string {
+			return q.terms(se, field)
+		})
+	}
+	return e
+}
+
+// explainFields explains the scores of the terms of a query in field, or
+// in every indexed field if field is empty.
+func (se *SearchEngine) explainFields(field string, docID int, scorer Scorer, terms func(field string) []string) []Explanation {
+	if scorer == nil {
+		scorer = se.scorer
+	}
+	fields := []string{field}
+	if field == "" {
+		fields = se.indexedFields()
+	}
+	segments := se.searchableSegments()
+	var details []Explanation
+	for _, field := range fields {
+		fieldStats := FieldStats{
+			Field:     field,
+			DocCount:  maxDoc(segments),
+			AvgLength: se.avgFieldLength(field),
+			SumLength: se.fieldLength[field],
+		}
+		for _, term := range terms(field) {
+			lists := termPostings(segments, field, term)
+			freq := 0
+			for _, postings := range lists {
+				if it := postings.Iterator(); it.Advance(docID) && it.Posting().DocID == docID {
+					freq = it.Posting().Freq
+				}
+			}
+			if freq == 0 {
+				continue
+			}
+			termStats := TermStats{Term: term, DocFreq: docFreq(lists), TotalTermFreq: totalTermFreq(lists)}
+			doc := DocStats{
+				DocID:  docID,
+				Freq:   freq,
+				Length: se.fieldDocLength(field, docID),
+				Norm:   se.fieldDocNorm(field, docID),
+			}
+			var detail Explanation
+			if explainer, ok := scorer.(ScoreExplainer); ok {
+				detail = explainer.Explain(termStats, doc, fieldStats)
+			} else {
+				detail = Explanation{
+					Value: scorer.Score(termStats, doc, fieldStats),
+					Description: fmt.Sprintf("score with tf = %d, df = %d, N = %d, dl = %d, avgdl = %.2f",
+						doc.Freq, termStats.DocFreq, fieldStats.DocCount, doc.Length, fieldStats.AvgLength),
+				}
+			}
+			detail.Description = "weight of " + fieldPrefix(field) + term + ", " + detail.Description
+			details = append(details, detail)
+		}
+	}
+	return details
+}
+
+// explainAnalyzer returns the analyzer a query on field is analyzed with
+// to match docID.
+func (se *SearchEngine) explainAnalyzer(field string, docID int) *Analyzer {
+	if !se.languageAnalyzed(field) {
+		return se.queryAnalyzer(field)
+	}
+	if analyzer, ok := se.languages[se.documents[docID].Language]; ok {
+		return analyzer
+	}
+	return se.searchAnalyzer
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode"
+)
+
+var ErrExpressionSyntax = errors.New("expression syntax error")
+
+// Expression is a compiled formula over the score of a document and its
+// numeric doc values, such as `_score * log(2 + rating)`. It supports
+// numbers, + - * / ^ and parentheses, _score, field names, which stand for
+// their numeric doc value (0 for documents without one), and the functions
+// log (natural), log10, log1p, exp, sqrt, abs, min, max and pow. Results
+// that are not finite, such as log(0) or sqrt(-1), score 0.
+type Expression struct {
+	source string
+	eval   exprFunc
+}
+
+// exprFunc evaluates an expression for a document with the given score.
+type exprFunc func(se *SearchEngine, docID int, score float64) float64
+
+// ParseExpression compiles an expression, or returns an error wrapping
+// ErrExpressionSyntax.
+func ParseExpression(source string) (*Expression, error) {
+	p := &exprParser{src: []rune(source)}
+	eval, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.i < len(p.src) {
+		return nil, p.errorf("unexpected %q", string(p.src[p.i]))
+	}
+	return &Expression{source: source, eval: eval}, nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// ScriptScoreQuery replaces the scores of Query with Script evaluated for
+// each matching document.
+type ScriptScoreQuery struct {
+	Query  Query
+	Script *Expression
+}
+
+// NewScriptScoreQuery returns a query scoring the matches of q with the
+// expression script.
+func NewScriptScoreQuery(q Query, script string) (ScriptScoreQuery, error) {
+	expr, err := ParseExpression(script)
+	if err != nil {
+		return ScriptScoreQuery{}, err
+	}
+	return ScriptScoreQuery{Query: q, Script: expr}, nil
+}
+
+func (q ScriptScoreQuery) score(se *SearchEngine) map[int]float64 {
+	scores := q.Query.score(se)
+	for docID, score := range scores {
+		score = q.Script.eval(se, docID, score)
+		if math.IsNaN(score) || math.IsInf(score, 0) {
+			score = 0
+		}
+		scores[docID] = score
+	}
+	return scores
+}
+
+func (q ScriptScoreQuery) String() string {
+	return "script(" + q.Query.String() + ", " + q.Script.String() + ")"
+}
+
+type exprParser struct {
+	src []rune
+	i   int
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at offset %d", ErrExpressionSyntax, fmt.Sprintf(format, args...), p.i)
+}
+
+func (p *exprParser) skipSpace() {
+	for p.i < len(p.src) && unicode.IsSpace(p.src[p.i]) {
+		p.i++
+	}
+}
+
+// accept consumes r if it comes next.
+func (p *exprParser) accept(r rune) bool {
+	p.skipSpace()
+	if p.i < len(p.src) && p.src[p.i] == r {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// parseSum parses terms joined by + and -.
+func (p *exprParser) parseSum() (exprFunc, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op rune
+		switch {
+		case p.accept('+'):
+			op = '+'
+		case p.accept('-'):
+			op = '-'
+		default:
+			return left, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(op, left, right)
+	}
+}
+
+// parseProduct parses factors joined by * and /.
+func (p *exprParser) parseProduct() (exprFunc, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op rune
+		switch {
+		case p.accept('*'):
+			op = '*'
+		case p.accept('/'):
+			op = '/'
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(op, left, right)
+	}
+}
+
+func (p *exprParser) parseUnary() (exprFunc, error) {
+	if p.accept('-') {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(se *SearchEngine, docID int, score float64) float64 {
+			return -operand(se, docID, score)
+		}, nil
+	}
+	return p.parsePower()
+}
+
+// parsePower parses a ^ b, which is right associative.
+func (p *exprParser) parsePower() (exprFunc, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept('^') {
+		return base, nil
+	}
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return binaryExpr('^', base, exponent), nil
+}
+
+func (p *exprParser) parsePrimary() (exprFunc, error) {
+	p.skipSpace()
+	if p.i == len(p.src) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	r := p.src[p.i]
+	switch {
+	case r == '(':
+		p.i++
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, p.errorf(`expected ")"`)
+		}
+		return inner, nil
+	case unicode.IsDigit(r) || r == '.':
+		start := p.i
+		for p.i < len(p.src) && (unicode.IsDigit(p.src[p.i]) || p.src[p.i] == '.') {
+			p.i++
+		}
+		value, err := strconv.ParseFloat(string(p.src[start:p.i]), 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", string(p.src[start:p.i]))
+		}
+		return func(*SearchEngine, int, float64) float64 { return value }, nil
+	case unicode.IsLetter(r) || r == '_':
+		start := p.i
+		for p.i < len(p.src) && (unicode.IsLetter(p.src[p.i]) || unicode.IsDigit(p.src[p.i]) || p.src[p.i] == '_' || p.src[p.i] == '.') {
+			p.i++
+		}
+		name := string(p.src[start:p.i])
+		if p.accept('(') {
+			return p.parseCall(name)
+		}
+		if name == "_score" {
+			return func(_ *SearchEngine, _ int, score float64) float64 { return score }, nil
+		}
+		return func(se *SearchEngine, docID int, _ float64) float64 {
+			value, _ := se.docValues.numberValue(name, docID)
+			return value
+		}, nil
+	}
+	return nil, p.errorf("unexpected %q", string(r))
+}
+
+// exprFunctions maps the function names of expressions to their arity and
+// implementation.
+var exprFunctions = map[string]struct {
+	arity int
+	fn    func(args []float64) float64
+}{
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"log1p": {1, func(a []float64) float64 { return math.Log1p(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+}
+
+// parseCall parses the arguments of a call to name, after its "(".
+func (p *exprParser) parseCall(name string) (exprFunc, error) {
+	function, ok := exprFunctions[name]
+	if !ok {
+		return nil, p.errorf("unknown function %q", name)
+	}
+	var args []exprFunc
+	for !p.accept(')') {
+		if len(args) > 0 && !p.accept(',') {
+			return nil, p.errorf(`expected "," or ")"`)
+		}
+		arg, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) != function.arity {
+		return nil, p.errorf("%s takes %d arguments, got %d", name, function.arity, len(args))
+	}
+	return func(se *SearchEngine, docID int, score float64) float64 {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg(se, docID, score)
+		}
+		return function.fn(values)
+	}, nil
+}
+
+func binaryExpr(op rune, left, right exprFunc) exprFunc {
+	return func(se *SearchEngine, docID int, score float64) float64 {
+		a, b := left(se, docID, score), right(se, docID, score)
+		switch op {
+		case '+':
+			return a + b
+		case '-':
+			return a - b
+		case '*':
+			return a * b
+		case '/':
+			return a / b
+		}
+		return math.Pow(a, b)
+	}
+}
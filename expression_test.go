@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScriptScoreNonFinite(t *testing.T) {
+	docs := []Document{
+		{ID: "good", Fields: map[string]interface{}{"body": "fox", "rating": 4}},
+		{ID: "zero", Fields: map[string]interface{}{"body": "fox", "rating": 0}},
+		{ID: "negative", Fields: map[string]interface{}{"body": "fox", "rating": -1}},
+	}
+	se := NewSearchEngine(docs, Config{Fields: map[string]FieldOptions{"rating": {Type: FieldInt}}})
+	q, err := NewScriptScoreQuery(MatchQuery{Field: "body", Text: "fox"}, "log(rating)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := se.SearchQuery(q, SearchOptions{})
+	if len(result.Hits) != 3 {
+		t.Fatalf("got %d hits, want 3", len(result.Hits))
+	}
+	if result.Hits[0].ID != "good" {
+		t.Errorf("first hit is %q, want good", result.Hits[0].ID)
+	}
+	want := map[string]float64{"good": math.Log(4), "zero": 0, "negative": 0}
+	for _, hit := range result.Hits {
+		if hit.Score != want[hit.ID] {
+			t.Errorf("%s scores %v, want %v", hit.ID, hit.Score, want[hit.ID])
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "sort"
+
+// FacetCount is the number of matching documents with a value of a field.
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+// facets counts the keyword values of fields over the documents of
+// scores, a document counting once for each of its distinct values,
+// keeping the size most frequent values of each field, ties broken by
+// value.
+func (se *SearchEngine) facets(scores map[int]float64, fields []string, size int) map[string][]FacetCount {
+	if size <= 0 {
+		size = defaultSize
+	}
+	facets := make(map[string][]FacetCount, len(fields))
+	for _, field := range fields {
+		counts := make(map[string]int)
+		for docID := range scores {
+			values := se.docValues.keywordValues(field, docID)
+			for i, value := range values {
+				if !contains(values[:i], value) {
+					counts[value]++
+				}
+			}
+		}
+		facet := make([]FacetCount, 0, len(counts))
+		for value, count := range counts {
+			facet = append(facet, FacetCount{value, count})
+		}
+		sort.Slice(facet, func(i, j int) bool {
+			if facet[i].Count != facet[j].Count {
+				return facet[i].Count > facet[j].Count
+			}
+			return facet[i].Value < facet[j].Value
+		})
+		if len(facet) > size {
+			facet = facet[:size]
+		}
+		facets[field] = facet
+	}
+	return facets
+}
@@ -0,0 +1,38 @@
+package main
+
+// FieldOptions configures how a document field is analyzed and kept.
+// Queries on the field use SearchAnalyzer, or Analyzer when SearchAnalyzer
+// is nil. By default a field is both indexed and stored.
+type FieldOptions struct {
+	// Type is the type of the values of the field, FieldText by default.
+	Type           FieldType
+	Analyzer       *Analyzer
+	SearchAnalyzer *Analyzer
+	// StoreOnly keeps the field in the returned documents without indexing
+	// it, for values that are displayed but never searched.
+	StoreOnly bool
+	// IndexOnly makes the field searchable without keeping its value in
+	// memory; it is left out of the returned documents.
+	IndexOnly bool
+	// DocValues additionally keeps the field in a column for sorting,
+	// range filters and facets.
+	DocValues DocValuesType
+	// DateLayouts are the time.Parse layouts tried in turn on the values
+	// of a DateDocValues field, DefaultDateLayouts if empty.
+	DateLayouts []string
+}
+
+// KeywordField matches the field only by its exact value.
+func KeywordField() FieldOptions {
+	return FieldOptions{Analyzer: NewKeywordAnalyzer()}
+}
+
+// AutocompleteField indexes the prefixes of every term produced by analyzer,
+// while queries are analyzed without them, so a partially typed word matches
+// the terms it starts.
+func AutocompleteField(analyzer *Analyzer, minGram, maxGram int) FieldOptions {
+	return FieldOptions{
+		Analyzer:       analyzer.WithTokenFilters(NewEdgeNGramFilter(minGram, maxGram)),
+		SearchAnalyzer: analyzer,
+	}
+}
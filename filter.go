@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxCachedFilters bounds the filter cache; when it is full it is emptied.
+const maxCachedFilters = 256
+
+// filterDocs returns the documents matching a filter clause. The result is
+// cached until the next write, so a filter repeated across searches, such
+// as a tenant or a category, is only run once. Filters holding funcs or
+// pointers are not cached, as their key would not tell apart closures or
+// pointees that differ.
+func (se *SearchEngine) filterDocs(q Query) *Bitmap {
+	if q, ok := q.(BoolFieldQuery); ok {
+		// Already a bitmap, not worth a cache entry.
+		return q.docs(se)
+	}
+	if !cacheable(reflect.ValueOf(q)) {
+		return scoredDocs(q.score(se))
+	}
+	// %#v spells out the type and every parameter of the query.
+	key := fmt.Sprintf("%#v", q)
+	se.filterMu.Lock()
+	docs, ok := se.filterCache[key]
+	se.filterMu.Unlock()
+	if ok {
+		return docs
+	}
+
+	docs = NewBitmap()
+	for docID := range q.score(se) {
+		docs.Add(uint32(docID))
+	}
+
+	se.filterMu.Lock()
+	defer se.filterMu.Unlock()
+	if se.filterCache == nil || len(se.filterCache) >= maxCachedFilters {
+		se.filterCache = make(map[string]*Bitmap)
+	}
+	se.filterCache[key] = docs
+	return docs
+}
+
+// cacheable reports whether v is made of plain values only, which %#v
+// prints in full.
+func cacheable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Func, reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return false
+	case reflect.Interface:
+		return v.IsNil() || cacheable(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !cacheable(v.Field(i)) {
+				return false
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !cacheable(v.Index(i)) {
+				return false
+			}
+		}
+	case reflect.Map:
+		for it := v.MapRange(); it.Next(); {
+			if !cacheable(it.Key()) || !cacheable(it.Value()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// clearFilterCache drops the cached filters after a write. se.rw must be
+// held for writing.
+func (se *SearchEngine) clearFilterCache() {
+	se.filterCache = nil
+}
+
+// TermsQuery matches the documents containing any of Terms exactly as
+// given, for filtering on a set of values such as categories. Every match
+// scores 1. An empty Field matches every indexed field.
+type TermsQuery struct {
+	Field string
+	Terms []string
+}
+
+func (q TermsQuery) score(se *SearchEngine) map[int]float64 {
+	scores := make(map[int]float64)
+	fields := []string{q.Field}
+	if q.Field == "" {
+		fields = se.indexedFields()
+	}
+	segments := se.searchableSegments()
+	for _, field := range fields {
+		for _, term := range q.Terms {
+			for _, postings := range termPostings(segments, field, term) {
+				for it := postings.Iterator(); it.Next(); {
+					if docID := it.Posting().DocID; !se.isDeleted(docID) {
+						scores[docID] = 1
+					}
+				}
+			}
+		}
+	}
+	return scores
+}
+
+func (q TermsQuery) String() string {
+	return fieldPrefix(q.Field) + "(" + strings.Join(q.Terms, " ") + ")"
+}
+
+// BoolFieldQuery matches the documents whose boolean field Field has the
+// value Value, straight from the bitmaps the field keeps (see FieldBool).
+// The query parser turns field:true and field:false into one for fields
+// mapped as FieldBool. Every match scores 1.
+type BoolFieldQuery struct {
+	Field string
+	Value bool
+}
+
+// docs returns the live documents matching q. se.rw must be held.
+func (q BoolFieldQuery) docs(se *SearchEngine) *Bitmap {
+	return se.docValues.boolDocs(q.Field, q.Value).AndNot(se.deleted)
+}
+
+func (q BoolFieldQuery) score(se *SearchEngine) map[int]float64 {
+	scores := make(map[int]float64)
+	for it := q.docs(se).Iterator(); it.Next(); {
+		scores[int(it.Value())] = 1
+	}
+	return scores
+}
+
+func (q BoolFieldQuery) String() string {
+	return fieldPrefix(q.Field) + strconv.FormatBool(q.Value)
+}
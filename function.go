@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FunctionScoreQuery modifies the scores of Query with functions of the
+// documents, such as their popularity or distance. The values of the
+// functions that apply to a document are combined by ScoreMode, and the
+// result with the score of the query by BoostMode. A document no function
+// applies to keeps its score.
+type FunctionScoreQuery struct {
+	Query     Query
+	Functions []ScoreFunction
+	// ScoreMode combines the functions: CombineMultiply (the default),
+	// CombineSum or CombineMax.
+	ScoreMode CombineMode
+	// BoostMode combines the functions with the query: CombineMultiply
+	// (the default), CombineSum, CombineMax or CombineReplace.
+	BoostMode CombineMode
+}
+
+// CombineMode is how a FunctionScoreQuery combines values.
+type CombineMode int
+
+const (
+	CombineMultiply CombineMode = iota
+	CombineSum
+	CombineMax
+	// CombineReplace replaces the score of the query with the value of the
+	// functions.
+	CombineReplace
+)
+
+var combineModeNames = []string{"multiply", "sum", "max", "replace"}
+
+func (m CombineMode) String() string {
+	if m < 0 || int(m) >= len(combineModeNames) {
+		return "CombineMode(" + strconv.Itoa(int(m)) + ")"
+	}
+	return combineModeNames[m]
+}
+
+// combine combines a and b.
+func (m CombineMode) combine(a, b float64) float64 {
+	switch m {
+	case CombineSum:
+		return a + b
+	case CombineMax:
+		return math.Max(a, b)
+	case CombineReplace:
+		return b
+	}
+	return a * b
+}
+
+// ScoreFunction computes a value for a document to score it by.
+type ScoreFunction interface {
+	// value returns the value for docID, or false if the function does not
+	// apply to it. se.rw must be held.
+	value(se *SearchEngine, docID int) (float64, bool)
+	String() string
+}
+
+func (q FunctionScoreQuery) score(se *SearchEngine) map[int]float64 {
+	scores := q.Query.score(se)
+	for docID, score := range scores {
+		if value, ok := q.functionValue(se, docID); ok {
+			scores[docID] = q.BoostMode.combine(score, value)
+		}
+	}
+	return scores
+}
+
+// functionValue combines the values of the functions for docID.
+func (q FunctionScoreQuery) functionValue(se *SearchEngine, docID int) (float64, bool) {
+	var combined float64
+	applied := false
+	for _, fn := range q.Functions {
+		value, ok := fn.value(se, docID)
+		if !ok {
+			continue
+		}
+		if !applied {
+			combined, applied = value, true
+		} else {
+			combined = q.ScoreMode.combine(combined, value)
+		}
+	}
+	return combined, applied
+}
+
+func (q FunctionScoreQuery) String() string {
+	functions := make([]string, len(q.Functions))
+	for i, fn := range q.Functions {
+		functions[i] = fn.String()
+	}
+	return "function_score(" + q.Query.String() + ", [" + strings.Join(functions, ", ") + "], " +
+		q.ScoreMode.String() + ", " + q.BoostMode.String() + ")"
+}
+
+// Weight is a constant function, applying to the documents that match
+// Filter, or to every document if Filter is nil.
+type Weight struct {
+	Weight float64
+	Filter Query
+}
+
+func (w Weight) value(se *SearchEngine, docID int) (float64, bool) {
+	if w.Filter != nil && !se.filterDocs(w.Filter).Contains(uint32(docID)) {
+		return 0, false
+	}
+	return w.Weight, true
+}
+
+func (w Weight) String() string {
+	s := "weight(" + strconv.FormatFloat(w.Weight, 'g', -1, 64)
+	if w.Filter != nil {
+		s += ", " + w.Filter.String()
+	}
+	return s + ")"
+}
+
+// ValueModifier is applied to a field value by FieldValueFactor.
+type ValueModifier int
+
+const (
+	ModifierNone ValueModifier = iota
+	// ModifierLog1p is log10(1 + v).
+	ModifierLog1p
+	// ModifierLn1p is ln(1 + v).
+	ModifierLn1p
+	ModifierSqrt
+	ModifierSquare
+	// ModifierReciprocal is 1 / v.
+	ModifierReciprocal
+)
+
+var valueModifierNames = []string{"", "log1p", "ln1p", "sqrt", "square", "reciprocal"}
+
+func (m ValueModifier) apply(v float64) float64 {
+	switch m {
+	case ModifierLog1p:
+		return math.Log10(1 + v)
+	case ModifierLn1p:
+		return math.Log1p(v)
+	case ModifierSqrt:
+		return math.Sqrt(v)
+	case ModifierSquare:
+		return v * v
+	case ModifierReciprocal:
+		return 1 / v
+	}
+	return v
+}
+
+// FieldValueFactor is Modifier applied to Factor times the numeric doc
+// value of Field, as in log1p(1 * views). It does not apply to documents
+// without a value. A zero Factor is 1.
+type FieldValueFactor struct {
+	Field    string
+	Factor   float64
+	Modifier ValueModifier
+}
+
+func (f FieldValueFactor) value(se *SearchEngine, docID int) (float64, bool) {
+	v, ok := se.docValues.numberValue(f.Field, docID)
+	if !ok {
+		return 0, false
+	}
+	factor := f.Factor
+	if factor == 0 {
+		factor = 1
+	}
+	return f.Modifier.apply(factor * v), true
+}
+
+func (f FieldValueFactor) String() string {
+	factor := f.Factor
+	if factor == 0 {
+		factor = 1
+	}
+	s := strconv.FormatFloat(factor, 'g', -1, 64) + " * " + f.Field
+	if f.Modifier > ModifierNone && int(f.Modifier) < len(valueModifierNames) {
+		s = valueModifierNames[f.Modifier] + "(" + s + ")"
+	}
+	return s
+}
+
+// DecayShape is the curve of a decay function.
+type DecayShape int
+
+const (
+	DecayGauss DecayShape = iota
+	DecayExp
+	DecayLinear
+)
+
+var decayShapeNames = []string{"gauss", "exp", "linear"}
+
+func (shape DecayShape) String() string {
+	if shape < 0 || int(shape) >= len(decayShapeNames) {
+		return "DecayShape(" + strconv.Itoa(int(shape)) + ")"
+	}
+	return decayShapeNames[shape]
+}
+
+// decay returns the value of the curve at distance, given the distance
+// Scale at which it reaches decay.
+func (shape DecayShape) decay(distance, scale, decay float64) float64 {
+	switch shape {
+	case DecayExp:
+		return math.Exp(math.Log(decay) / scale * distance)
+	case DecayLinear:
+		s := scale / (1 - decay)
+		return math.Max(0, (s-distance)/s)
+	}
+	sigma2 := -scale * scale / (2 * math.Log(decay))
+	return math.Exp(-distance * distance / (2 * sigma2))
+}
+
+// DecayFunction scores documents by how far the numeric doc value of Field
+// is from Origin: 1 within Offset of it, falling along Shape to Decay
+// (0.5 if zero) at Offset + Scale. It does not apply to documents without
+// a value. NewDateDecay builds one for a date field.
+type DecayFunction struct {
+	Field  string
+	Origin float64
+	Scale  float64
+	Offset float64
+	Decay  float64
+	Shape  DecayShape
+}
+
+// NewDateDecay returns a decay function on a date field, by the time from
+// origin.
+func NewDateDecay(field string, origin time.Time, scale, offset time.Duration, shape DecayShape) DecayFunction {
+	return DecayFunction{
+		Field:  field,
+		Origin: float64(origin.UnixMilli()),
+		Scale:  float64(scale.Milliseconds()),
+		Offset: float64(offset.Milliseconds()),
+		Shape:  shape,
+	}
+}
+
+func (f DecayFunction) value(se *SearchEngine, docID int) (float64, bool) {
+	v, ok := se.docValues.numberValue(f.Field, docID)
+	if !ok {
+		return 0, false
+	}
+	return decayValue(f.Shape, math.Abs(v-f.Origin), f.Scale, f.Offset, f.Decay), true
+}
+
+func (f DecayFunction) String() string {
+	return fmt.Sprintf("%s(%s, origin=%g, scale=%g, offset=%g, decay=%g)",
+		f.Shape, f.Field, f.Origin, f.Scale, f.Offset, f.Decay)
+}
+
+// GeoDecayFunction is a DecayFunction on the distance in meters between
+// the point (Lat, Lon) and the point of a document, whose latitude and
+// longitude in degrees are the numeric doc values of LatField and
+// LonField.
+type GeoDecayFunction struct {
+	LatField, LonField string
+	Lat, Lon           float64
+	Scale              float64
+	Offset             float64
+	Decay              float64
+	Shape              DecayShape
+}
+
+func (f GeoDecayFunction) value(se *SearchEngine, docID int) (float64, bool) {
+	lat, ok := se.docValues.numberValue(f.LatField, docID)
+	if !ok {
+		return 0, false
+	}
+	lon, ok := se.docValues.numberValue(f.LonField, docID)
+	if !ok {
+		return 0, false
+	}
+	return decayValue(f.Shape, haversine(f.Lat, f.Lon, lat, lon), f.Scale, f.Offset, f.Decay), true
+}
+
+func (f GeoDecayFunction) String() string {
+	return fmt.Sprintf("%s(%s,%s, origin=%g,%g, scale=%gm, offset=%gm, decay=%g)",
+		f.Shape, f.LatField, f.LonField, f.Lat, f.Lon, f.Scale, f.Offset, f.Decay)
+}
+
+func decayValue(shape DecayShape, distance, scale, offset, decay float64) float64 {
+	if decay <= 0 || decay >= 1 {
+		decay = 0.5
+	}
+	distance = math.Max(0, distance-offset)
+	if scale <= 0 {
+		if distance == 0 {
+			return 1
+		}
+		return 0
+	}
+	return shape.decay(distance, scale, decay)
+}
+
+// earthRadius is the mean radius of the Earth in meters.
+const earthRadius = 6371008.8
+
+// haversine returns the great-circle distance in meters between two points
+// given in degrees.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := math.Pi / 180
+	dLat := (lat2 - lat1) * toRadians
+	dLon := (lon2 - lon1) * toRadians
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRadians)*math.Cos(lat2*toRadians)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
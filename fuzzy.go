@@ -0,0 +1,171 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultMaxEdits is the edit distance of a fuzzy query written without
+// one, as in hobit~.
+const defaultMaxEdits = 2
+
+// FuzzyQuery matches the documents containing a term within MaxEdits
+// insertions, deletions or substitutions of Term. The term is lowercased
+// but not otherwise analyzed. It expands to the MaxExpansions closest
+// terms, 128 if zero. An empty Field matches every indexed field. Scorer
+// overrides the scorer of the index.
+type FuzzyQuery struct {
+	Field         string
+	Term          string
+	MaxEdits      int
+	MaxExpansions int
+	Scorer        Scorer
+}
+
+func (q FuzzyQuery) score(se *SearchEngine) map[int]float64 {
+	return se.scoreFields(q.Field, func(field string) map[int]float64 {
+		return se.scoreTokens(field, q.terms(se, field), q.Scorer)
+	})
+}
+
+// terms returns the terms of field the query expands to.
+func (q FuzzyQuery) terms(se *SearchEngine, field string) []string {
+	return se.fuzzyTerms(field, strings.ToLower(q.Term), q.MaxEdits, q.MaxExpansions)
+}
+
+func (q FuzzyQuery) String() string {
+	return fieldPrefix(q.Field) + q.Term + "~" + strconv.Itoa(q.MaxEdits)
+}
+
+// parseFuzzy splits a query word such as hobit~1 into its term and edit
+// distance.
+func parseFuzzy(word string) (term string, maxEdits int, ok bool) {
+	i := strings.LastIndexByte(word, '~')
+	if i <= 0 {
+		return "", 0, false
+	}
+	if i == len(word)-1 {
+		return word[:i], defaultMaxEdits, true
+	}
+	maxEdits, err := strconv.Atoi(word[i+1:])
+	if err != nil || maxEdits < 0 {
+		return "", 0, false
+	}
+	return word[:i], maxEdits, true
+}
+
+// fuzzyTerms returns the terms of a field within maxEdits of term, closest
+// first, at most max of them (defaultMaxExpansions if zero).
+func (se *SearchEngine) fuzzyTerms(field, term string, maxEdits, max int) []string {
+	if max <= 0 {
+		max = defaultMaxExpansions
+	}
+	distances := make(map[string]int)
+	for _, s := range se.searchableSegments() {
+		s.fields[field].WalkFuzzy(term, maxEdits, func(term string, distance int, postings PostingList) bool {
+			distances[term] = distance
+			return true
+		})
+	}
+	terms := make([]string, 0, len(distances))
+	for term := range distances {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if distances[terms[i]] != distances[terms[j]] {
+			return distances[terms[i]] < distances[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > max {
+		terms = terms[:max]
+	}
+	return terms
+}
+
+// WalkFuzzy calls fn for every term within maxEdits insertions, deletions
+// or substitutions of term, with its distance, until fn returns false. It
+// computes the edit distance a character at a time along the tree and
+// skips every subtree whose prefix is already too far from term, so only a
+// small part of the dictionary is visited.
+func (idx *InvertedIndex) WalkFuzzy(term string, maxEdits int, fn func(term string, distance int, postings PostingList) bool) {
+	if idx == nil {
+		return
+	}
+	w := &fuzzyWalker{target: []rune(term), maxEdits: maxEdits, fn: fn}
+	// row[i] is the distance between the walked prefix and the first i
+	// runes of term.
+	row := make([]int, len(w.target)+1)
+	for i := range row {
+		row[i] = i
+	}
+	w.walk(&idx.root, "", nil, row)
+}
+
+type fuzzyWalker struct {
+	target   []rune
+	maxEdits int
+	fn       func(string, int, PostingList) bool
+}
+
+// walk visits the subtree of n. Labels split terms at byte boundaries, so
+// pending holds the bytes of a rune started by an ancestor's label.
+func (w *fuzzyWalker) walk(n *termNode, prefix string, pending []byte, row []int) bool {
+	for i := 0; i < len(n.label); i++ {
+		pending = append(pending, n.label[i])
+		if !utf8.FullRune(pending) {
+			continue
+		}
+		r, _ := utf8.DecodeRune(pending)
+		pending = pending[:0]
+		row = w.step(row, r)
+		if minInt(row) > w.maxEdits {
+			return true
+		}
+	}
+	prefix += n.label
+	if n.postings != nil && len(pending) == 0 && row[len(row)-1] <= w.maxEdits {
+		if !w.fn(prefix, row[len(row)-1], n.postings) {
+			return false
+		}
+	}
+	for _, c := range n.children {
+		// Each child gets its own copy of the state, as walk extends it.
+		if !w.walk(c, prefix, append([]byte(nil), pending...), row) {
+			return false
+		}
+	}
+	return true
+}
+
+// step returns the distance row after appending r to the walked prefix.
+func (w *fuzzyWalker) step(row []int, r rune) []int {
+	next := make([]int, len(row))
+	next[0] = row[0] + 1
+	for i := 1; i < len(row); i++ {
+		cost := 1
+		if w.target[i-1] == r {
+			cost = 0
+		}
+		next[i] = next[i-1] + 1
+		if d := row[i] + 1; d < next[i] {
+			next[i] = d
+		}
+		if d := row[i-1] + cost; d < next[i] {
+			next[i] = d
+		}
+	}
+	return next
+}
+
+func minInt(values []int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// HighlightOptions configures how the matched terms of hits are marked.
+type HighlightOptions struct {
+	// PreTag and PostTag surround every matched term, <em> and </em> if
+	// both are empty.
+	PreTag, PostTag string
+	// Fields restricts highlighting to the named fields.
+	Fields []string
+	// FragmentSize, if positive, replaces the text of a field longer than
+	// that many bytes by snippets of about that size around the densest
+	// clusters of matched terms, at most NumFragments of them (1 if zero),
+	// in the order they appear.
+	FragmentSize int
+	NumFragments int
+}
+
+// highlight returns the stored text of the fields of doc that contain one
+// of terms, or snippets of it, with the occurrences of the terms marked.
+// The text is the one the tokens were taken from, after the char filters
+// of the field.
+func (se *SearchEngine) highlight(doc Document, terms []fieldTerm, opts HighlightOptions) map[string][]string {
+	if opts.PreTag == "" && opts.PostTag == "" {
+		opts.PreTag, opts.PostTag = "<em>", "</em>"
+	}
+	byField := make(map[string]map[string]bool)
+	for _, t := range terms {
+		if byField[t.field] == nil {
+			byField[t.field] = make(map[string]bool)
+		}
+		byField[t.field][t.term] = true
+	}
+	if len(opts.Fields) > 0 {
+		selected := make(map[string]map[string]bool, len(opts.Fields))
+		for _, field := range opts.Fields {
+			if fieldTerms, ok := byField[field]; ok {
+				selected[field] = fieldTerms
+			}
+		}
+		byField = selected
+	}
+
+	var highlights map[string][]string
+	for field, fieldTerms := range byField {
+		text := doc.Field(field)
+		if text == "" {
+			continue
+		}
+		analyzer := se.indexAnalyzer(doc, field)
+		text = analyzer.filterChars(text)
+		tokens := analyzer.analyzeFiltered(text)
+		var matches []Token
+		for _, token := range tokens {
+			if fieldTerms[token.Term] {
+				matches = append(matches, token)
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i].Start < matches[j].Start
+		})
+		fragments := []fragment{{0, len(text)}}
+		if opts.FragmentSize > 0 && len(text) > opts.FragmentSize {
+			numFragments := opts.NumFragments
+			if numFragments <= 0 {
+				numFragments = 1
+			}
+			fragments = bestFragments(len(text), tokens, matches, opts.FragmentSize, numFragments)
+		}
+		if highlights == nil {
+			highlights = make(map[string][]string)
+		}
+		for _, f := range fragments {
+			highlights[field] = append(highlights[field], markTokens(text, matches, f, opts.PreTag, opts.PostTag))
+		}
+	}
+	return highlights
+}
+
+// fragment is the part of a text from byte start to end.
+type fragment struct {
+	start, end int
+}
+
+// bestFragments picks the n fragments of about size bytes of a text of
+// length bytes that hold the most distinct matched terms, then the most
+// matches, without overlapping, and returns them in text order. Each is
+// centered on its matches and cut at token boundaries. matches must be
+// sorted by offset.
+func bestFragments(length int, tokens, matches []Token, size, n int) []fragment {
+	type candidate struct {
+		fragment
+		distinct, count int
+	}
+	var candidates []candidate
+	for i := range matches {
+		start, end := matches[i].Start, matches[i].End
+		terms := make(map[string]bool)
+		count := 0
+		for _, m := range matches[i:] {
+			if m.End-start > size {
+				break
+			}
+			terms[m.Term] = true
+			count++
+			if m.End > end {
+				end = m.End
+			}
+		}
+		candidates = append(candidates, candidate{centerFragment(length, tokens, start, end, size), len(terms), count})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].distinct != candidates[j].distinct {
+			return candidates[i].distinct > candidates[j].distinct
+		}
+		return candidates[i].count > candidates[j].count
+	})
+
+	var fragments []fragment
+	for _, c := range candidates {
+		if len(fragments) == n {
+			break
+		}
+		overlapping := false
+		for _, f := range fragments {
+			if c.start < f.end && f.start < c.end {
+				overlapping = true
+				break
+			}
+		}
+		if !overlapping {
+			fragments = append(fragments, c.fragment)
+		}
+	}
+	sort.Slice(fragments, func(i, j int) bool {
+		return fragments[i].start < fragments[j].start
+	})
+	return fragments
+}
+
+// centerFragment widens the span from start to end to about size bytes on
+// both sides, without cutting a token.
+func centerFragment(length int, tokens []Token, start, end, size int) fragment {
+	from := start - (size-(end-start))/2
+	if from+size > length {
+		from = length - size
+	}
+	if from < 0 {
+		from = 0
+	}
+	to := from + size
+	f := fragment{start, end}
+	if from == 0 {
+		f.start = 0
+	}
+	if to >= length {
+		f.end = length
+	}
+	for _, token := range tokens {
+		if token.Start >= from && token.Start < f.start {
+			f.start = token.Start
+		}
+		if token.End <= to && token.End > f.end {
+			f.end = token.End
+		}
+	}
+	return f
+}
+
+// markTokens returns fragment f of text with the tokens in it surrounded
+// by pre and post, merging the tokens that overlap, such as synonyms or
+// n-grams of the same word. tokens must be sorted by offset.
+func markTokens(text string, tokens []Token, f fragment, pre, post string) string {
+	var b strings.Builder
+	last := f.start
+	for i := 0; i < len(tokens); {
+		start, end := tokens[i].Start, tokens[i].End
+		for i++; i < len(tokens) && tokens[i].Start < end; i++ {
+			if tokens[i].End > end {
+				end = tokens[i].End
+			}
+		}
+		if start < f.start || end > f.end {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(pre)
+		b.WriteString(text[start:end])
+		b.WriteString(post)
+		last = end
+	}
+	b.WriteString(text[last:f.end])
+	return b.String()
+}
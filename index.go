@@ -0,0 +1,87 @@
+package main
+
+import "sort"
+
+// Occurrence is a single occurrence of a term in a field: its token
+// position and its byte offsets.
+type Occurrence struct {
+	Position   int
+	Start, End int
+}
+
+// Posting records how often and where a term occurs in one document.
+// Occurrences is sorted by position and left empty when the index does not
+// store positions.
+type Posting struct {
+	DocID       int
+	Freq        int
+	Occurrences []Occurrence
+}
+
+// InvertedIndex maps every term to its postings, which are sorted by
+// DocID and hold at most one posting per document. Terms are kept in a
+// radix tree, so they can be enumerated in order or by prefix and terms
+// sharing a prefix share its storage.
+type InvertedIndex struct {
+	root termNode
+	size int
+}
+
+// IndexOptions controls what BuildInvertedIndex stores.
+type IndexOptions struct {
+	// Positions records the occurrences of every term.
+	Positions bool
+	// Compress stores the posting lists delta and varint encoded.
+	Compress bool
+	// BitmapThreshold, when positive, stores the posting lists of terms
+	// found in more than this fraction of the documents as bitmaps.
+	BitmapThreshold float64
+}
+
+// BuildInvertedIndex indexes a single field of the documents. Postings refer
+// to documents by their index in documents.
+func BuildInvertedIndex(documents []Document, field string, analyzerFor func(Document) *Analyzer, options IndexOptions) *InvertedIndex {
+	buffer := newSegment()
+	for i, doc := range documents {
+		tokens := analyzeValues(analyzerFor(doc), doc.FieldValues(field))
+		buffer.add(i, map[string][]Token{field: tokens}, options.Positions)
+	}
+	if index, ok := buffer.freeze(newDocValues(), options).fields[field]; ok {
+		return index
+	}
+	return &InvertedIndex{}
+}
+
+// normalizePostings sorts postings by DocID and merges postings of the same
+// document, which happen when documents are not given in ID order or an ID
+// is repeated.
+func normalizePostings(postings []Posting) []Posting {
+	sorted := true
+	for i := 1; i < len(postings); i++ {
+		if postings[i-1].DocID >= postings[i].DocID {
+			sorted = false
+			break
+		}
+	}
+	if sorted {
+		return postings
+	}
+
+	sort.SliceStable(postings, func(i, j int) bool {
+		return postings[i].DocID < postings[j].DocID
+	})
+	merged := postings[:1]
+	for _, posting := range postings[1:] {
+		last := &merged[len(merged)-1]
+		if posting.DocID != last.DocID {
+			merged = append(merged, posting)
+			continue
+		}
+		last.Freq += posting.Freq
+		last.Occurrences = append(last.Occurrences, posting.Occurrences...)
+		sort.Slice(last.Occurrences, func(i, j int) bool {
+			return last.Occurrences[i].Position < last.Occurrences[j].Position
+		})
+	}
+	return merged
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizePostings(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []Posting
+		want     []Posting
+	}{
+		{
+			name:     "empty",
+			postings: nil,
+			want:     nil,
+		},
+		{
+			name:     "out of order",
+			postings: []Posting{{DocID: 5, Freq: 1}, {DocID: 1, Freq: 2}, {DocID: 3, Freq: 3}},
+			want:     []Posting{{DocID: 1, Freq: 2}, {DocID: 3, Freq: 3}, {DocID: 5, Freq: 1}},
+		},
+		{
+			name: "repeated",
+			postings: []Posting{
+				{DocID: 2, Freq: 2, Occurrences: []Occurrence{{Position: 4, Start: 20, End: 23}, {Position: 9, Start: 40, End: 43}}},
+				{DocID: 1, Freq: 1, Occurrences: []Occurrence{{Position: 0, Start: 0, End: 3}}},
+				{DocID: 2, Freq: 1, Occurrences: []Occurrence{{Position: 1, Start: 4, End: 7}}},
+			},
+			want: []Posting{
+				{DocID: 1, Freq: 1, Occurrences: []Occurrence{{Position: 0, Start: 0, End: 3}}},
+				{DocID: 2, Freq: 3, Occurrences: []Occurrence{{Position: 1, Start: 4, End: 7}, {Position: 4, Start: 20, End: 23}, {Position: 9, Start: 40, End: 43}}},
+			},
+		},
+		{
+			name:     "repeated without positions",
+			postings: []Posting{{DocID: 7, Freq: 1}, {DocID: 7, Freq: 4}},
+			want:     []Posting{{DocID: 7, Freq: 5}},
+		},
+	}
+	for _, tt := range tests {
+		if got := normalizePostings(tt.postings); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: normalizePostings = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizePostingsKeepsSortedInput(t *testing.T) {
+	postings := []Posting{
+		{DocID: 1, Freq: 1, Occurrences: []Occurrence{{Position: 3}}},
+		{DocID: 4, Freq: 2, Occurrences: []Occurrence{{Position: 0}, {Position: 2}}},
+		{DocID: 9, Freq: 1, Occurrences: []Occurrence{{Position: 5}}},
+	}
+	want := append([]Posting(nil), postings...)
+	got := normalizePostings(postings)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizePostings = %v, want %v", got, want)
+	}
+	if &got[0] != &postings[0] {
+		t.Error("sorted postings were copied")
+	}
+}
+
+func TestBuildInvertedIndex(t *testing.T) {
+	documents := []Document{
+		{ID: "d", Fields: map[string]interface{}{"body": "fox dog"}},
+		{ID: "a", Fields: map[string]interface{}{"body": "dog"}},
+		{ID: "c", Fields: map[string]interface{}{"body": "dog fox fox"}},
+		{ID: "b", Fields: map[string]interface{}{"body": "fox"}},
+	}
+	// Postings refer to documents by their index, whatever their IDs.
+	want := []Posting{
+		{DocID: 0, Freq: 1, Occurrences: []Occurrence{{Position: 0, Start: 0, End: 3}}},
+		{DocID: 2, Freq: 2, Occurrences: []Occurrence{{Position: 1, Start: 4, End: 7}, {Position: 2, Start: 8, End: 11}}},
+		{DocID: 3, Freq: 1, Occurrences: []Occurrence{{Position: 0, Start: 0, End: 3}}},
+	}
+	standard := NewStandardAnalyzer()
+	for _, options := range []IndexOptions{{Positions: true}, {Positions: true, Compress: true}} {
+		index := BuildInvertedIndex(documents, "body", func(Document) *Analyzer { return standard }, options)
+		list, _ := index.Get("fox")
+		if got := decodeAll(list); !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: postings of fox = %v, want %v", options, got, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+var SpanishStopWords = []string{
+	"a", "al", "como", "con", "de", "del", "el", "en", "es", "esta", "este",
+	"la", "las", "le", "lo", "los", "mas", "más", "me", "mi", "no", "o", "para",
+	"pero", "por", "que", "se", "si", "sin", "sobre", "su", "sus", "un", "una",
+	"y", "ya",
+}
+
+var GermanStopWords = []string{
+	"aber", "als", "am", "an", "auch", "auf", "aus", "bei", "bin", "bis", "das",
+	"dass", "dem", "den", "der", "des", "die", "doch", "du", "ein", "eine",
+	"einen", "er", "es", "für", "hat", "ich", "im", "in", "ist", "mit", "nach",
+	"nicht", "noch", "oder", "sich", "sie", "sind", "und", "von", "war", "wie",
+	"zu", "zum", "zur",
+}
+
+var languageStopWords = map[string][]string{
+	"en": EnglishStopWords,
+	"es": SpanishStopWords,
+	"de": GermanStopWords,
+}
+
+// LanguageAnalyzers returns an analyzer for every language DetectLanguage
+// knows about, keyed by language code.
+func LanguageAnalyzers() map[string]*Analyzer {
+	return map[string]*Analyzer{
+		"en": NewEnglishAnalyzer(),
+		"es": NewSpanishAnalyzer(),
+		"de": NewGermanAnalyzer(),
+	}
+}
+
+// DetectLanguage guesses which of the candidate languages the text is
+// written in by counting the stopwords of each language it contains. It
+// returns "" when no candidate has any stopword in the text.
+func DetectLanguage(text string, candidates []string) string {
+	counts := make(map[string]int)
+	for _, token := range LowercaseFilter(UnicodeTokenizer(text)) {
+		for _, language := range candidates {
+			if contains(languageStopWords[language], token.Term) {
+				counts[language]++
+			}
+		}
+	}
+	sorted := append([]string(nil), candidates...)
+	sort.Strings(sorted)
+	best, bestCount := "", 0
+	for _, language := range sorted {
+		if counts[language] > bestCount {
+			best, bestCount = language, counts[language]
+		}
+	}
+	return best
+}
+
+func NewSpanishAnalyzer() *Analyzer {
+	return &Analyzer{
+		CharFilters: []CharFilter{NFCCharFilter},
+		Tokenizer:   UnicodeTokenizer,
+		TokenFilters: []TokenFilter{
+			LowercaseFilter,
+			NewStopFilter(SpanishStopWords),
+			SpanishStemFilter,
+		},
+	}
+}
+
+func NewGermanAnalyzer() *Analyzer {
+	return &Analyzer{
+		CharFilters: []CharFilter{NFCCharFilter},
+		Tokenizer:   UnicodeTokenizer,
+		TokenFilters: []TokenFilter{
+			LowercaseFilter,
+			NewStopFilter(GermanStopWords),
+			GermanStemFilter,
+		},
+	}
+}
+
+func SpanishStemFilter(tokens []Token) []Token {
+	return mapTerms(tokens, SpanishStem)
+}
+
+func GermanStemFilter(tokens []Token) []Token {
+	return mapTerms(tokens, GermanStem)
+}
+
+var spanishAccents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ä", "a",
+	"è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i",
+	"ò", "o", "ó", "o", "ô", "o", "ö", "o",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u",
+)
+
+// SpanishStem is a light stemmer that removes accents and plural and
+// gender endings.
+func SpanishStem(word string) string {
+	s := []rune(spanishAccents.Replace(word))
+	n := len(s)
+	if n < 5 {
+		return string(s)
+	}
+	switch s[n-1] {
+	case 'o', 'a', 'e':
+		return string(s[:n-1])
+	case 's':
+		if s[n-2] == 'e' && s[n-3] == 's' && s[n-4] == 'e' {
+			return string(s[:n-2])
+		}
+		if s[n-2] == 'e' && s[n-3] == 'c' {
+			s[n-3] = 'z'
+			return string(s[:n-2])
+		}
+		if s[n-2] == 'o' || s[n-2] == 'a' || s[n-2] == 'e' {
+			return string(s[:n-2])
+		}
+	}
+	return string(s)
+}
+
+var germanUmlauts = strings.NewReplacer(
+	"ä", "a", "à", "a", "á", "a", "â", "a",
+	"ö", "o", "ò", "o", "ó", "o", "ô", "o",
+	"ü", "u", "ù", "u", "ú", "u", "û", "u",
+	"ï", "i", "ì", "i", "í", "i", "î", "i",
+	"ß", "ss",
+)
+
+func isGermanStEnding(r rune) bool {
+	switch r {
+	case 'b', 'd', 'f', 'g', 'h', 'k', 'l', 'm', 'n', 't':
+		return true
+	}
+	return false
+}
+
+// GermanStem is a light stemmer that removes umlauts and common inflectional
+// endings.
+func GermanStem(word string) string {
+	s := []rune(germanUmlauts.Replace(word))
+	s = germanStemStep1(s)
+	return string(germanStemStep2(s))
+}
+
+func hasRuneSuffix(s []rune, suffix string) bool {
+	return strings.HasSuffix(string(s), suffix)
+}
+
+func germanStemStep1(s []rune) []rune {
+	n := len(s)
+	switch {
+	case n > 5 && hasRuneSuffix(s, "ern"):
+		return s[:n-3]
+	case n > 4 && (hasRuneSuffix(s, "em") || hasRuneSuffix(s, "en") || hasRuneSuffix(s, "er") || hasRuneSuffix(s, "es")):
+		return s[:n-2]
+	case n > 3 && s[n-1] == 'e':
+		return s[:n-1]
+	case n > 3 && s[n-1] == 's' && isGermanStEnding(s[n-2]):
+		return s[:n-1]
+	}
+	return s
+}
+
+func germanStemStep2(s []rune) []rune {
+	n := len(s)
+	switch {
+	case n > 5 && hasRuneSuffix(s, "est"):
+		return s[:n-3]
+	case n > 4 && (hasRuneSuffix(s, "er") || hasRuneSuffix(s, "en")):
+		return s[:n-2]
+	case n > 4 && hasRuneSuffix(s, "st") && isGermanStEnding(s[n-3]):
+		return s[:n-2]
+	}
+	return s
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrIndexExists   = errors.New("index already exists")
+	ErrIndexNotFound = errors.New("index not found")
+	ErrAliasNotFound = errors.New("alias not found")
+)
+
+// Engine hosts several named indexes, each a SearchEngine with its own
+// analyzers and settings. An index can also be reached through aliases,
+// which can be pointed at another index atomically: to rebuild an index
+// without downtime, create the new index under a fresh name, swap the alias
+// the application searches to it and delete the old index. An Engine is
+// safe for concurrent use.
+type Engine struct {
+	mu      sync.RWMutex
+	indexes map[string]*SearchEngine
+	aliases map[string]string
+}
+
+func NewEngine() *Engine {
+	return &Engine{
+		indexes: make(map[string]*SearchEngine),
+		aliases: make(map[string]string),
+	}
+}
+
+// CreateIndex builds a new index named name from the documents, or fails
+// with the error of the first document that does not fit the mapping.
+func (e *Engine) CreateIndex(name string, documents []Document, config Config) (*SearchEngine, error) {
+	e.mu.RLock()
+	err := e.checkName(name)
+	e.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Indexing may take a while, so it runs without holding the lock.
+	index := newSearchEngine(config)
+	for _, doc := range documents {
+		if err := index.putDocument(doc); err != nil {
+			return nil, err
+		}
+	}
+	index.Flush()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.checkName(name); err != nil {
+		return nil, err
+	}
+	e.indexes[name] = index
+	return index, nil
+}
+
+// checkName reports whether name is free for a new index or alias. e.mu
+// must be held.
+func (e *Engine) checkName(name string) error {
+	if _, ok := e.indexes[name]; ok {
+		return fmt.Errorf("%w: %q", ErrIndexExists, name)
+	}
+	if _, ok := e.aliases[name]; ok {
+		return fmt.Errorf("%w: %q is an alias", ErrIndexExists, name)
+	}
+	return nil
+}
+
+// DeleteIndex removes an index along with the aliases pointing at it.
+// Searches already running against it are not affected.
+func (e *Engine) DeleteIndex(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.indexes[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrIndexNotFound, name)
+	}
+	delete(e.indexes, name)
+	for alias, target := range e.aliases {
+		if target == name {
+			delete(e.aliases, alias)
+		}
+	}
+	return nil
+}
+
+// Index returns the index named name, or the index an alias named name
+// points at.
+func (e *Engine) Index(name string) (*SearchEngine, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if target, ok := e.aliases[name]; ok {
+		name = target
+	}
+	index, ok := e.indexes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrIndexNotFound, name)
+	}
+	return index, nil
+}
+
+// SwapAlias points alias at index, creating the alias if needed, and
+// returns the index it pointed at before. Searches through the alias move
+// to the new index at once.
+func (e *Engine) SwapAlias(alias, index string) (previous string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.indexes[index]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrIndexNotFound, index)
+	}
+	if _, ok := e.indexes[alias]; ok {
+		return "", fmt.Errorf("%w: %q", ErrIndexExists, alias)
+	}
+	previous = e.aliases[alias]
+	e.aliases[alias] = index
+	return previous, nil
+}
+
+// RemoveAlias removes an alias, leaving its index in place.
+func (e *Engine) RemoveAlias(alias string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.aliases[alias]; !ok {
+		return fmt.Errorf("%w: %q", ErrAliasNotFound, alias)
+	}
+	delete(e.aliases, alias)
+	return nil
+}
+
+// Aliases returns a copy of the alias table, mapping each alias to its
+// index.
+func (e *Engine) Aliases() map[string]string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	aliases := make(map[string]string, len(e.aliases))
+	for alias, index := range e.aliases {
+		aliases[alias] = index
+	}
+	return aliases
+}
+
+// Indexes returns the names of all indexes in sorted order.
+func (e *Engine) Indexes() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	names := make([]string, 0, len(e.indexes))
+	for name := range e.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Search runs the query against the index or alias named name.
+func (e *Engine) Search(name, query string, opts SearchOptions) (SearchResult, error) {
+	index, err := e.Index(name)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return index.Search(query, opts), nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidField = errors.New("invalid field value")
+
+// FieldType is the type of the values of a field, declared by
+// FieldOptions.Type. Each type implies the analysis and doc values of the
+// field unless FieldOptions sets them, and documents whose values do not
+// fit the type of their fields are rejected. Every element of a
+// multi-valued field must fit the type; fields with numeric or date doc
+// values, and geo fields, take a single value.
+type FieldType int
+
+const (
+	// FieldText is analyzed free text, the type of unmapped fields. Any
+	// value fits it, indexed as its text.
+	FieldText FieldType = iota
+	// FieldKeyword is a string matched and sorted by its exact value.
+	FieldKeyword
+	// FieldInt is an integer: a Go integer or a string of one.
+	FieldInt
+	// FieldFloat is a number: any Go number or a string of one.
+	FieldFloat
+	// FieldDate is a time.Time or a string in one of the DateLayouts.
+	FieldDate
+	// FieldBool is a bool or the string "true" or "false". The documents
+	// with each value are kept in bitmaps, which BoolFieldQuery filters on.
+	FieldBool
+	// FieldGeo is a GeoPoint or a "lat,lon" string. It is not indexed; its
+	// latitude and longitude are numeric doc values of the fields
+	// field.lat and field.lon, as used by GeoDecayFunction.
+	FieldGeo
+)
+
+var fieldTypeNames = []string{"text", "keyword", "int", "float", "date", "bool", "geo"}
+
+func (t FieldType) String() string {
+	if t < 0 || int(t) >= len(fieldTypeNames) {
+		return "FieldType(" + strconv.Itoa(int(t)) + ")"
+	}
+	return fieldTypeNames[t]
+}
+
+// GeoPoint is a location in degrees.
+type GeoPoint struct {
+	Lat, Lon float64
+}
+
+func (p GeoPoint) String() string {
+	return strconv.FormatFloat(p.Lat, 'g', -1, 64) + "," + strconv.FormatFloat(p.Lon, 'g', -1, 64)
+}
+
+// withTypeDefaults fills in the analysis and doc values implied by the
+// type of a field.
+func (o FieldOptions) withTypeDefaults() FieldOptions {
+	switch o.Type {
+	case FieldKeyword, FieldInt, FieldFloat, FieldBool:
+		if o.Analyzer == nil {
+			o.Analyzer = NewKeywordAnalyzer()
+		}
+	case FieldGeo:
+		o.StoreOnly = true
+	}
+	if o.DocValues == NoDocValues {
+		switch o.Type {
+		case FieldKeyword, FieldBool:
+			o.DocValues = KeywordDocValues
+		case FieldInt, FieldFloat:
+			o.DocValues = NumericDocValues
+		case FieldDate:
+			o.DocValues = DateDocValues
+		}
+	}
+	return o
+}
+
+// ValidateDocument checks the values of a document against the types of
+// their fields, returning an error wrapping ErrInvalidField for the first
+// that does not fit, or ErrUnknownField for a field the mapping has no
+// room for. Fields missing from the mapping are checked against the type
+// they would be mapped to.
+func (se *SearchEngine) ValidateDocument(doc Document) error {
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+	doc.Fields = flattenFields(doc.Fields)
+	_, err := se.checkDocument(doc)
+	return err
+}
+
+// checkDocument validates a document and returns the options of its fields
+// that are to be added to the mapping. se.rw must be held.
+func (se *SearchEngine) checkDocument(doc Document) (map[string]FieldOptions, error) {
+	var added map[string]FieldOptions
+	for _, name := range documentFields(doc) {
+		value := doc.Fields[name]
+		options, ok := se.fields[name]
+		if !ok {
+			var err error
+			if options, ok, err = se.dynamicOptions(name, value); err != nil {
+				return nil, fmt.Errorf("%w: %q of document %q", err, name, doc.ID)
+			}
+			if ok {
+				if added == nil {
+					added = make(map[string]FieldOptions)
+				}
+				added[name] = options
+			}
+		}
+		values := fieldValues(value)
+		if len(values) > 1 && !multiValued(options) {
+			return nil, fmt.Errorf("%w: %q of document %q has %d values, but %s fields take one", ErrInvalidField, name, doc.ID, len(values), singleValuedKind(options))
+		}
+		for _, value := range values {
+			if !validFieldValue(value, options) {
+				return nil, fmt.Errorf("%w: %q of document %q is not a valid %s: %v", ErrInvalidField, name, doc.ID, options.Type, value)
+			}
+		}
+	}
+	return added, nil
+}
+
+// addFields adds fields to the mapping. se.rw must be held for writing.
+func (se *SearchEngine) addFields(added map[string]FieldOptions) {
+	if len(added) == 0 {
+		return
+	}
+	fields := make(map[string]FieldOptions, len(se.fields)+len(added))
+	for name, options := range se.fields {
+		fields[name] = options
+	}
+	for name, options := range added {
+		fields[name] = options
+	}
+	se.fields = fields
+}
+
+// mapping returns the current mapping, which must not be modified.
+func (se *SearchEngine) mapping() map[string]FieldOptions {
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+	return se.fields
+}
+
+// Mapping returns a copy of the mapping: the options of the fields
+// declared in Config.Fields and of those mapped dynamically since.
+func (se *SearchEngine) Mapping() map[string]FieldOptions {
+	fields := se.mapping()
+	mapping := make(map[string]FieldOptions, len(fields))
+	for name, options := range fields {
+		mapping[name] = options
+	}
+	return mapping
+}
+
+// multiValued reports whether a field can hold several values: numeric
+// and date doc values and geo points keep a single one per document.
+func multiValued(options FieldOptions) bool {
+	switch {
+	case options.Type == FieldGeo:
+		return false
+	case options.DocValues == NumericDocValues, options.DocValues == DateDocValues:
+		return false
+	}
+	return true
+}
+
+// singleValuedKind names the kind of a field multiValued rejects.
+func singleValuedKind(options FieldOptions) string {
+	if options.Type == FieldGeo {
+		return "geo"
+	}
+	if options.DocValues == DateDocValues {
+		return "date"
+	}
+	return "numeric"
+}
+
+func validFieldValue(value interface{}, options FieldOptions) bool {
+	switch options.Type {
+	case FieldText:
+		return true
+	case FieldKeyword:
+		_, ok := value.(string)
+		return ok
+	case FieldInt:
+		switch v := value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		case string:
+			_, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return err == nil
+		}
+	case FieldFloat:
+		switch v := value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32:
+			return true
+		case float64:
+			return !math.IsNaN(v)
+		case string:
+			_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return err == nil
+		}
+	case FieldDate:
+		switch v := value.(type) {
+		case time.Time:
+			return true
+		case string:
+			_, ok := parseDate(v, options.DateLayouts)
+			return ok
+		}
+	case FieldBool:
+		switch v := value.(type) {
+		case bool:
+			return true
+		case string:
+			return v == "true" || v == "false"
+		}
+	case FieldGeo:
+		_, ok := geoPoint(value)
+		return ok
+	}
+	return false
+}
+
+// geoPoint returns the point a geo field value stands for.
+func geoPoint(value interface{}) (GeoPoint, bool) {
+	var p GeoPoint
+	switch v := value.(type) {
+	case GeoPoint:
+		p = v
+	case string:
+		lat, lon, ok := strings.Cut(v, ",")
+		if !ok {
+			return GeoPoint{}, false
+		}
+		var err error
+		if p.Lat, err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err != nil {
+			return GeoPoint{}, false
+		}
+		if p.Lon, err = strconv.ParseFloat(strings.TrimSpace(lon), 64); err != nil {
+			return GeoPoint{}, false
+		}
+	default:
+		return GeoPoint{}, false
+	}
+	if math.Abs(p.Lat) > 90 || math.Abs(p.Lon) > 180 {
+		return GeoPoint{}, false
+	}
+	return p, true
+}
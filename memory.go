@@ -0,0 +1,131 @@
+package main
+
+import "unsafe"
+
+// MemoryUsage is an estimate of the heap bytes held by an index, by
+// component. It counts the data and the slice, string and map headers
+// pointing at it, but not allocator overhead or unused map buckets, so the
+// real figure is somewhat higher. Indexing a sample of a corpus and scaling
+// the result gives a fair idea of the memory the full corpus will need.
+type MemoryUsage struct {
+	// TermDictionary is the radix trees of terms, without their postings.
+	TermDictionary int
+	Postings       int
+	// Documents is the stored documents and the ID mapping.
+	Documents int
+	// Norms is the token count of every field of every document.
+	Norms     int
+	DocValues int
+	// DocSets is the bitmaps of the documents in each segment and of the
+	// deleted documents.
+	DocSets int
+}
+
+func (m MemoryUsage) Total() int {
+	return m.TermDictionary + m.Postings + m.Documents + m.Norms + m.DocValues + m.DocSets
+}
+
+const (
+	pointerSize      = int(unsafe.Sizeof(uintptr(0)))
+	stringSize       = int(unsafe.Sizeof(""))
+	sliceSize        = int(unsafe.Sizeof([]int(nil)))
+	mapEntryOverhead = 2 * pointerSize
+)
+
+// MemoryUsage estimates the memory used by the index. Segments shared with
+// snapshots are counted in full.
+func (se *SearchEngine) MemoryUsage() MemoryUsage {
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+
+	var usage MemoryUsage
+	for _, s := range se.searchableSegments() {
+		usage.DocSets += s.docs.sizeInBytes()
+		for field, index := range s.fields {
+			usage.TermDictionary += stringSize + len(field) + mapEntryOverhead
+			dictionary, postings := index.root.sizeInBytes()
+			usage.TermDictionary += dictionary
+			usage.Postings += postings
+		}
+	}
+	usage.DocSets += se.deleted.sizeInBytes()
+
+	for _, doc := range se.documents {
+		usage.Documents += documentSize(doc)
+	}
+	usage.Documents += cap(se.fingerprints) * 8
+	for id := range se.ids {
+		usage.Documents += stringSize + len(id) + pointerSize + mapEntryOverhead
+	}
+	for field, lengths := range se.docLength {
+		usage.Norms += stringSize + len(field) + sliceSize + cap(lengths)*pointerSize
+	}
+	for field, norms := range se.docNorm {
+		usage.Norms += stringSize + len(field) + sliceSize + cap(norms)*8
+	}
+	for field, column := range se.docValues.numeric {
+		usage.DocValues += stringSize + len(field) + sliceSize + cap(column)*8
+	}
+	for field, bitmaps := range se.docValues.bools {
+		usage.DocValues += stringSize + len(field) + bitmaps[0].sizeInBytes() + bitmaps[1].sizeInBytes()
+	}
+	for field, column := range se.docValues.keyword {
+		usage.DocValues += stringSize + len(field) + sliceSize + cap(column)*sliceSize
+		for _, values := range column {
+			usage.DocValues += cap(values) * stringSize
+			for _, value := range values {
+				usage.DocValues += len(value)
+			}
+		}
+	}
+	return usage
+}
+
+func documentSize(doc Document) int {
+	size := int(unsafe.Sizeof(doc)) + len(doc.ID) + len(doc.Language)
+	for name, value := range doc.Fields {
+		// Values other than text are counted by the length of their text.
+		size += 2*stringSize + len(name) + len(fieldText(value)) + mapEntryOverhead
+	}
+	for name, value := range doc.Metadata {
+		size += 2*stringSize + len(name) + len(fieldText(value)) + mapEntryOverhead
+	}
+	return size
+}
+
+// sizeInBytes returns the size of the subtree rooted at n, split between
+// the tree itself and the posting lists hanging from it.
+func (n *termNode) sizeInBytes() (dictionary, postings int) {
+	dictionary = int(unsafe.Sizeof(*n)) + len(n.label) + cap(n.children)*pointerSize
+	if n.postings != nil {
+		postings = postingListSize(n.postings)
+	}
+	for _, child := range n.children {
+		d, p := child.sizeInBytes()
+		dictionary += d
+		postings += p
+	}
+	return dictionary, postings
+}
+
+func postingListSize(list PostingList) int {
+	switch list := list.(type) {
+	case Postings:
+		size := cap(list) * int(unsafe.Sizeof(Posting{}))
+		for _, posting := range list {
+			size += cap(posting.Occurrences) * int(unsafe.Sizeof(Occurrence{}))
+		}
+		return size
+	case EncodedPostings:
+		return cap(list.data) + cap(list.skips)*int(unsafe.Sizeof(skipPointer{}))
+	case *BitmapPostings:
+		size := int(unsafe.Sizeof(*list)) + list.docs.sizeInBytes() + cap(list.freqs)*pointerSize
+		size += cap(list.occurrences) * sliceSize
+		for _, occurrences := range list.occurrences {
+			size += cap(occurrences) * int(unsafe.Sizeof(Occurrence{}))
+		}
+		return size
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// DiversifyQuery reorders the WindowSize best matches of Query (100 if
+// zero) by Maximal Marginal Relevance, so the top results are not all
+// alike: each next result is the one maximizing
+//
+//	Lambda * relevance - (1 - Lambda) * similarity
+//
+// where relevance is its score divided by the best score and similarity
+// its highest cosine similarity to the results before it, by the terms of
+// their stored fields. Lambda 1 keeps the order of Query and 0 only
+// diversifies; 0.5 to 0.7 trades a little relevance for variety. Like
+// RerankQuery, it drops the documents outside the window.
+type DiversifyQuery struct {
+	Query      Query
+	Lambda     float64
+	WindowSize int
+}
+
+func (q DiversifyQuery) score(se *SearchEngine) map[int]float64 {
+	scores := q.Query.score(se)
+	window := q.WindowSize
+	if window <= 0 {
+		window = defaultRerankWindow
+	}
+	candidates := topDocIDs(scores, window, se.ranking(scores, nil))
+	if len(candidates) == 0 {
+		return scores
+	}
+	maxScore := scores[candidates[0]]
+	vectors := make(map[int]termFreqs, len(candidates))
+	for _, docID := range candidates {
+		vectors[docID] = se.storedTermVector(docID)
+	}
+
+	// similarity[docID] is the highest similarity of a candidate to the
+	// documents selected so far.
+	similarity := make(map[int]float64, len(candidates))
+	diversified := make(map[int]float64, len(candidates))
+	for len(candidates) > 0 {
+		best, bestValue := 0, 0.0
+		for i, docID := range candidates {
+			relevance := 0.0
+			if maxScore > 0 {
+				relevance = scores[docID] / maxScore
+			}
+			value := q.Lambda*relevance - (1-q.Lambda)*similarity[docID]
+			if i == 0 || value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+		selected := candidates[best]
+		diversified[selected] = bestValue
+		candidates = append(candidates[:best], candidates[best+1:]...)
+		for _, docID := range candidates {
+			if sim := vectors[selected].cosine(vectors[docID]); sim > similarity[docID] {
+				similarity[docID] = sim
+			}
+		}
+	}
+	return diversified
+}
+
+func (q DiversifyQuery) String() string {
+	return "diversify(" + q.Query.String() + ", " + strconv.FormatFloat(q.Lambda, 'g', -1, 64) + ", " + strconv.Itoa(q.WindowSize) + ")"
+}
+
+// termFreqs holds the frequencies of the field:term pairs of a document.
+type termFreqs map[string]float64
+
+// storedTermVector analyzes the stored indexed fields of a document.
+func (se *SearchEngine) storedTermVector(docID int) termFreqs {
+	doc := se.documents[docID]
+	vector := make(termFreqs)
+	for _, field := range documentFields(doc) {
+		if !se.isIndexed(field) {
+			continue
+		}
+		for _, token := range analyzeValues(se.indexAnalyzer(doc, field), doc.FieldValues(field)) {
+			vector[field+":"+token.Term]++
+		}
+	}
+	return vector
+}
+
+// cosine returns the cosine similarity of two term vectors.
+func (v termFreqs) cosine(other termFreqs) float64 {
+	var dot, norm, otherNorm float64
+	for key, freq := range v {
+		dot += freq * other[key]
+		norm += freq * freq
+	}
+	for _, freq := range other {
+		otherNorm += freq * freq
+	}
+	if norm == 0 || otherNorm == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(norm*otherNorm)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// defaultMaxQueryTerms is the number of terms of a MoreLikeThisQuery
+// without MaxQueryTerms.
+const defaultMaxQueryTerms = 25
+
+// MoreLikeThisQuery matches the documents sharing the most significant
+// terms of the document with the given ID, which it leaves out: the
+// MaxQueryTerms terms (25 if zero) with the highest tf * ln(N / df) in
+// Fields, or in every indexed field if empty. Terms occurring fewer than
+// MinTermFreq times in the document or in fewer than MinDocFreq documents
+// are skipped.
+type MoreLikeThisQuery struct {
+	ID            string
+	Fields        []string
+	MaxQueryTerms int
+	MinTermFreq   int
+	MinDocFreq    int
+}
+
+// MoreLikeThis returns the documents most similar to the document with the
+// given ID, for "related documents" lists.
+func (se *SearchEngine) MoreLikeThis(id string, opts SearchOptions) (SearchResult, error) {
+	se.rw.RLock()
+	_, ok := se.ids[id]
+	se.rw.RUnlock()
+	if !ok {
+		return SearchResult{}, fmt.Errorf("%w: %q", ErrDocumentNotFound, id)
+	}
+	return se.SearchQuery(MoreLikeThisQuery{ID: id}, opts), nil
+}
+
+func (q MoreLikeThisQuery) score(se *SearchEngine) map[int]float64 {
+	var like BooleanQuery
+	for _, t := range q.terms(se) {
+		like.Should = append(like.Should, TermQuery{Field: t.field, Term: t.term})
+	}
+	if len(like.Should) == 0 {
+		return make(map[int]float64)
+	}
+	scores := like.score(se)
+	delete(scores, se.ids[q.ID])
+	return scores
+}
+
+// terms returns the most significant terms of the document, best first.
+func (q MoreLikeThisQuery) terms(se *SearchEngine) []fieldTerm {
+	docID, ok := se.ids[q.ID]
+	if !ok {
+		return nil
+	}
+	fields := make(map[string]bool)
+	for _, field := range q.Fields {
+		fields[field] = true
+	}
+	segments := se.searchableSegments()
+	numDocs := float64(maxDoc(segments))
+
+	type weightedTerm struct {
+		fieldTerm
+		weight float64
+	}
+	var terms []weightedTerm
+	for field, vector := range se.termVector(docID) {
+		if len(fields) > 0 && !fields[field] {
+			continue
+		}
+		for _, tf := range vector {
+			if tf.Freq < q.MinTermFreq {
+				continue
+			}
+			df := docFreq(termPostings(segments, field, tf.Term))
+			if df < q.MinDocFreq || df == 0 {
+				continue
+			}
+			weight := float64(tf.Freq) * math.Log(numDocs/float64(df))
+			if weight > 0 {
+				terms = append(terms, weightedTerm{fieldTerm{field, tf.Term}, weight})
+			}
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].weight != terms[j].weight {
+			return terms[i].weight > terms[j].weight
+		}
+		if terms[i].field != terms[j].field {
+			return terms[i].field < terms[j].field
+		}
+		return terms[i].term < terms[j].term
+	})
+	max := q.MaxQueryTerms
+	if max <= 0 {
+		max = defaultMaxQueryTerms
+	}
+	if len(terms) > max {
+		terms = terms[:max]
+	}
+	significant := make([]fieldTerm, len(terms))
+	for i, t := range terms {
+		significant[i] = t.fieldTerm
+	}
+	return significant
+}
+
+func (q MoreLikeThisQuery) String() string {
+	return "like(" + strconv.Quote(q.ID) + ")"
+}
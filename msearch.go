@@ -0,0 +1,88 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// SearchRequest is one search of a MultiSearch: Query if set, else Text in
+// the syntax of ParseQuery. Index names the index or alias it runs against
+// in Engine.MultiSearch and is ignored by SearchEngine.MultiSearch.
+type SearchRequest struct {
+	Index   string
+	Text    string
+	Query   Query
+	Options SearchOptions
+}
+
+// MultiSearchResult is the result of one search of a MultiSearch, or the
+// error that kept it from running.
+type MultiSearchResult struct {
+	SearchResult
+	Err error
+}
+
+// MultiSearch runs independent searches in one call, such as the panels of
+// a dashboard, and returns their results in the same order. With parallel,
+// they run on up to GOMAXPROCS goroutines.
+func (se *SearchEngine) MultiSearch(requests []SearchRequest, parallel bool) []SearchResult {
+	results := make([]SearchResult, len(requests))
+	runBatch(len(requests), parallel, func(i int) {
+		results[i] = se.runRequest(requests[i])
+	})
+	return results
+}
+
+// MultiSearch is SearchEngine.MultiSearch against the indexes or aliases
+// named by the requests. A request naming no index fails with
+// ErrIndexNotFound without affecting the others.
+func (e *Engine) MultiSearch(requests []SearchRequest, parallel bool) []MultiSearchResult {
+	results := make([]MultiSearchResult, len(requests))
+	runBatch(len(requests), parallel, func(i int) {
+		index, err := e.Index(requests[i].Index)
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+		results[i].SearchResult = index.runRequest(requests[i])
+	})
+	return results
+}
+
+func (se *SearchEngine) runRequest(r SearchRequest) SearchResult {
+	if r.Query != nil {
+		return se.SearchQuery(r.Query, r.Options)
+	}
+	return se.Search(r.Text, r.Options)
+}
+
+// runBatch calls run for every index below n, in turn or, with parallel,
+// on up to GOMAXPROCS goroutines.
+func runBatch(n int, parallel bool, run func(i int)) {
+	if !parallel {
+		for i := 0; i < n; i++ {
+			run(i)
+		}
+		return
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				run(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MultiMatchType is how a MultiMatchQuery combines the scores of its
+// fields.
+type MultiMatchType int
+
+const (
+	// BestFields scores a document by its best matching field, plus
+	// TieBreaker times the scores of the other fields. It suits fields that
+	// compete to hold the text, such as a title and a body.
+	BestFields MultiMatchType = iota
+	// MostFields sums the scores of the fields, for fields that hold the
+	// same text analyzed differently.
+	MostFields
+	// CombinedFields scores the fields as one with BM25F, for fields that
+	// together hold a document, such as a title, a body and tags. It takes
+	// K1 and B from Scorer or the scorer of the index if either is BM25,
+	// and the usual values otherwise.
+	CombinedFields
+)
+
+// MultiMatchQuery runs a MatchQuery for Text on each of Fields and combines
+// the scores according to Type. A field may carry a weight, as in
+// "title^3"; an empty Fields matches every indexed field. Scorer overrides
+// the scorer of the index.
+type MultiMatchQuery struct {
+	Fields     []string
+	Text       string
+	Type       MultiMatchType
+	TieBreaker float64
+	Scorer     Scorer
+}
+
+func (q MultiMatchQuery) score(se *SearchEngine) map[int]float64 {
+	fields := q.Fields
+	if len(fields) == 0 {
+		fields = se.indexedFields()
+	}
+	if q.Type == CombinedFields {
+		names := make([]string, len(fields))
+		boosts := make([]float64, len(fields))
+		for i, spec := range fields {
+			names[i], boosts[i] = parseFieldBoost(spec)
+		}
+		return se.bm25fScores(names, boosts, q.Text, q.bm25(se))
+	}
+	scores := make(map[int]float64)
+	best := make(map[int]float64)
+	for _, spec := range fields {
+		field, boost := parseFieldBoost(spec)
+		for docID, score := range (MatchQuery{Field: field, Text: q.Text, Scorer: q.Scorer}).score(se) {
+			score *= boost
+			scores[docID] += score
+			if score > best[docID] {
+				best[docID] = score
+			}
+		}
+	}
+	if q.Type == BestFields {
+		for docID, sum := range scores {
+			scores[docID] = best[docID] + q.TieBreaker*(sum-best[docID])
+		}
+	}
+	return scores
+}
+
+// bm25 returns the BM25 parameters of a CombinedFields query.
+func (q MultiMatchQuery) bm25(se *SearchEngine) BM25 {
+	scorer := q.Scorer
+	if scorer == nil {
+		scorer = se.scorer
+	}
+	if params, ok := scorer.(BM25); ok {
+		return params
+	}
+	return NewBM25()
+}
+
+func (q MultiMatchQuery) String() string {
+	sep := " | "
+	switch q.Type {
+	case MostFields:
+		sep = " + "
+	case CombinedFields:
+		sep = " "
+	}
+	if len(q.Fields) == 0 {
+		return q.Text
+	}
+	return "(" + strings.Join(q.Fields, sep) + "):" + q.Text
+}
+
+// parseFieldBoost splits a field such as title^3 into its name and weight,
+// 1 if it has none.
+func parseFieldBoost(spec string) (string, float64) {
+	i := strings.LastIndexByte(spec, '^')
+	if i < 0 {
+		return spec, 1
+	}
+	boost, err := strconv.ParseFloat(spec[i+1:], 64)
+	if err != nil {
+		return spec, 1
+	}
+	return spec[:i], boost
+}
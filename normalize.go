@@ -0,0 +1,43 @@
+package main
+
+import "math"
+
+// Normalizer maps a raw score, given the best score of the query, to a
+// normalized one.
+type Normalizer func(score, maxScore float64) float64
+
+// MaxNormalize divides scores by the best score, so the best hit scores 1.
+// Scores below zero become 0.
+func MaxNormalize(score, maxScore float64) float64 {
+	if maxScore <= 0 || score <= 0 {
+		return 0
+	}
+	return score / maxScore
+}
+
+// Sigmoid returns a calibration mapping scores through the logistic curve
+// 1 / (1 + e^(-slope * (score - midpoint))), so a score of midpoint maps to
+// 0.5 whatever the other results. Unlike MaxNormalize, the result of a poor
+// query does not score 1; midpoint and slope are fitted on the scores of
+// known relevant and irrelevant documents.
+func Sigmoid(midpoint, slope float64) Normalizer {
+	return func(score, _ float64) float64 {
+		return 1 / (1 + math.Exp(-slope*(score-midpoint)))
+	}
+}
+
+// normalize maps the scores of the hits and the max score of r with
+// normalize.
+func (r *SearchResult) normalize(normalize Normalizer) {
+	maxScore := r.MaxScore
+	for i := range r.Hits {
+		hit := &r.Hits[i]
+		hit.Score = normalize(hit.Score, maxScore)
+		for j := range hit.InnerHits {
+			hit.InnerHits[j].Score = normalize(hit.InnerHits[j].Score, maxScore)
+		}
+	}
+	if r.Total > 0 {
+		r.MaxScore = normalize(maxScore, maxScore)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// PhraseQuery matches the documents where the terms of Text, once analyzed
+// for Field, appear next to each other and in order. With a Slop, the terms
+// may be up to that many position moves away from the exact phrase, in any
+// order, and closer matches score higher. An empty Field matches every
+// indexed field. If the index has no positions (Config.OmitPositions), it
+// matches the documents containing every term.
+type PhraseQuery struct {
+	Field string
+	Text  string
+	Slop  int
+}
+
+func (q PhraseQuery) score(se *SearchEngine) map[int]float64 {
+	return se.scoreFields(q.Field, func(field string) map[int]float64 {
+		return se.scoreAnalyzed(field, q.Text, func(tokens []Token) map[int]float64 {
+			return se.phraseScores(field, tokens, q.Slop)
+		})
+	})
+}
+
+func (q PhraseQuery) String() string {
+	if q.Slop > 0 {
+		return fieldPrefix(q.Field) + `"` + q.Text + `"~` + strconv.Itoa(q.Slop)
+	}
+	return fieldPrefix(q.Field) + `"` + q.Text + `"`
+}
+
+// phraseScores scores the documents containing the phrase made of tokens
+// by its frequency and the sum of the idf of its terms.
+func (se *SearchEngine) phraseScores(field string, tokens []Token, slop int) map[int]float64 {
+	scores := make(map[int]float64)
+	if len(tokens) == 0 {
+		return scores
+	}
+	segments := se.searchableSegments()
+	numDocs := maxDoc(segments)
+	idf := 0.0
+	for _, token := range tokens {
+		lists := termPostings(segments, field, token.Term)
+		if len(lists) == 0 {
+			return scores
+		}
+		idf += math.Log(float64(numDocs) / float64(docFreq(lists)))
+	}
+
+	for _, s := range segments {
+		lists := make([]PostingList, 0, len(tokens))
+		for _, token := range tokens {
+			if list, ok := s.postings(field, token.Term); ok {
+				lists = append(lists, list)
+			}
+		}
+		if len(lists) < len(tokens) {
+			continue
+		}
+		iterators := make([]PostingIterator, len(lists))
+		for i, list := range lists {
+			iterators[i] = list.Iterator()
+		}
+		occurrences := make([][]Occurrence, len(tokens))
+		for _, docID := range IntersectPostings(lists...) {
+			if se.isDeleted(docID) {
+				continue
+			}
+			for i, it := range iterators {
+				it.Advance(docID)
+				occurrences[i] = it.Posting().Occurrences
+			}
+			if freq := se.phraseFreq(tokens, occurrences, slop); freq > 0 {
+				scores[docID] = freq * idf
+			}
+		}
+	}
+	return scores
+}
+
+// phraseFreq counts the occurrences of a phrase in a document, given the
+// occurrences of each of its tokens in the document. A sloppy match counts
+// as 1/(distance+1).
+func (se *SearchEngine) phraseFreq(tokens []Token, occurrences [][]Occurrence, slop int) float64 {
+	if !se.indexOptions.Positions {
+		return 1
+	}
+	if slop > 0 {
+		return sloppyPhraseFreq(tokens, occurrences, slop)
+	}
+	freq := 0.0
+	for _, first := range occurrences[0] {
+		start := first.Position - tokens[0].Position
+		if start < 0 {
+			continue
+		}
+		matched := true
+		for i := 1; i < len(tokens) && matched; i++ {
+			matched = hasPosition(occurrences[i], start+tokens[i].Position)
+		}
+		if matched {
+			freq++
+		}
+	}
+	return freq
+}
+
+// sloppyPhraseFreq finds the matches of a sloppy phrase. Shifting the
+// positions of each token by its position in the phrase, an exact match
+// has every token at the same shifted position; the distance of a match is
+// the spread between its first and last shifted positions. The windows
+// holding one occurrence of every token are walked the way a k-way merge
+// walks sorted lists, always moving the token that is furthest behind.
+func sloppyPhraseFreq(tokens []Token, occurrences [][]Occurrence, slop int) float64 {
+	cursors := make(phraseCursors, len(tokens))
+	end := math.MinInt
+	for i, token := range tokens {
+		cursors[i] = &phraseCursor{occurrences: occurrences[i], offset: token.Position}
+		if p := cursors[i].position(); p > end {
+			end = p
+		}
+	}
+	heap.Init(&cursors)
+
+	freq := 0.0
+	for {
+		first := cursors[0]
+		if distance := end - first.position(); distance <= slop {
+			freq += 1 / float64(distance+1)
+		}
+		first.i++
+		if first.i == len(first.occurrences) {
+			return freq
+		}
+		if p := first.position(); p > end {
+			end = p
+		}
+		heap.Fix(&cursors, 0)
+	}
+}
+
+type phraseCursor struct {
+	occurrences []Occurrence
+	offset      int
+	i           int
+}
+
+func (c *phraseCursor) position() int {
+	return c.occurrences[c.i].Position - c.offset
+}
+
+// phraseCursors is a min-heap of cursors by position.
+type phraseCursors []*phraseCursor
+
+func (h phraseCursors) Len() int            { return len(h) }
+func (h phraseCursors) Less(i, j int) bool  { return h[i].position() < h[j].position() }
+func (h phraseCursors) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *phraseCursors) Push(x interface{}) { *h = append(*h, x.(*phraseCursor)) }
+func (h *phraseCursors) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// hasPosition reports whether an occurrence is at position; occurrences
+// are sorted by position.
+func hasPosition(occurrences []Occurrence, position int) bool {
+	i := sort.Search(len(occurrences), func(i int) bool {
+		return occurrences[i].Position >= position
+	})
+	return i < len(occurrences) && occurrences[i].Position == position
+}
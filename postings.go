@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// skipInterval is the number of postings between two skip pointers of an
+// encoded posting list.
+const skipInterval = 64
+
+// PostingList is a posting list in any of its representations.
+type PostingList interface {
+	// Len returns the number of documents in the list.
+	Len() int
+	Iterator() PostingIterator
+}
+
+// PostingIterator steps through a posting list in DocID order. Next or
+// Advance must be called before the first Posting.
+type PostingIterator interface {
+	Next() bool
+	// Advance moves to the first posting at or after the current one whose
+	// DocID is at least target, skipping ahead without visiting the
+	// postings in between where the representation allows it.
+	Advance(target int) bool
+	Posting() Posting
+}
+
+// Postings is an uncompressed posting list.
+type Postings []Posting
+
+func (p Postings) Len() int {
+	return len(p)
+}
+
+func (p Postings) Iterator() PostingIterator {
+	return &postingsIterator{postings: p, i: -1}
+}
+
+type postingsIterator struct {
+	postings Postings
+	i        int
+}
+
+func (it *postingsIterator) Next() bool {
+	it.i++
+	return it.i < len(it.postings)
+}
+
+// Advance gallops ahead in doubling steps until it passes target, then
+// binary searches the last step.
+func (it *postingsIterator) Advance(target int) bool {
+	if it.i < 0 {
+		it.i = 0
+	}
+	if it.i >= len(it.postings) || it.postings[it.i].DocID >= target {
+		return it.i < len(it.postings)
+	}
+	lo, step := it.i, 1
+	for lo+step < len(it.postings) && it.postings[lo+step].DocID < target {
+		lo += step
+		step *= 2
+	}
+	hi := lo + step
+	if hi > len(it.postings) {
+		hi = len(it.postings)
+	}
+	it.i = lo + 1 + sort.Search(hi-lo-1, func(k int) bool {
+		return it.postings[lo+1+k].DocID >= target
+	})
+	return it.i < len(it.postings)
+}
+
+func (it *postingsIterator) Posting() Posting {
+	return it.postings[it.i]
+}
+
+// EncodedPostings is a posting list compressed into a byte slice. Doc IDs,
+// positions and start offsets are stored as the varint-encoded difference
+// to the previous value, which keeps them to a byte or two for dense lists.
+// Every skipInterval postings a skip pointer records where the next block
+// starts, so Advance can jump over blocks without decoding them.
+type EncodedPostings struct {
+	data  []byte
+	count int
+	skips []skipPointer
+}
+
+// skipPointer marks the start of a block: the byte offset of its first
+// posting and the DocID preceding it, which the first delta is relative to.
+type skipPointer struct {
+	lastDocID int
+	offset    int
+}
+
+func EncodePostings(postings []Posting) EncodedPostings {
+	var data []byte
+	buf := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v int) {
+		data = append(data, buf[:binary.PutUvarint(buf, uint64(v))]...)
+	}
+	putVarint := func(v int) {
+		data = append(data, buf[:binary.PutVarint(buf, int64(v))]...)
+	}
+
+	var skips []skipPointer
+	lastDocID := 0
+	for i, posting := range postings {
+		if i > 0 && i%skipInterval == 0 {
+			skips = append(skips, skipPointer{lastDocID: lastDocID, offset: len(data)})
+		}
+		putUvarint(posting.DocID - lastDocID)
+		putUvarint(posting.Freq)
+		putUvarint(len(posting.Occurrences))
+		lastPosition, lastStart := 0, 0
+		for _, occurrence := range posting.Occurrences {
+			putVarint(occurrence.Position - lastPosition)
+			putVarint(occurrence.Start - lastStart)
+			putUvarint(occurrence.End - occurrence.Start)
+			lastPosition, lastStart = occurrence.Position, occurrence.Start
+		}
+		lastDocID = posting.DocID
+	}
+	return EncodedPostings{data: data, count: len(postings), skips: skips}
+}
+
+func (e EncodedPostings) Len() int {
+	return e.count
+}
+
+// Size returns the number of bytes used by the encoded list.
+func (e EncodedPostings) Size() int {
+	return len(e.data)
+}
+
+func (e EncodedPostings) Iterator() PostingIterator {
+	return &encodedPostingsIterator{data: e.data, skips: e.skips}
+}
+
+// Decode returns the uncompressed posting list.
+func (e EncodedPostings) Decode() Postings {
+	postings := make(Postings, 0, e.count)
+	for it := e.Iterator(); it.Next(); {
+		postings = append(postings, it.Posting())
+	}
+	return postings
+}
+
+type encodedPostingsIterator struct {
+	data    []byte
+	skips   []skipPointer
+	offset  int
+	started bool
+	// exhausted is set once Next has run past the last posting.
+	exhausted bool
+	posting   Posting
+}
+
+func (it *encodedPostingsIterator) uvarint() int {
+	v, n := binary.Uvarint(it.data[it.offset:])
+	it.offset += n
+	return int(v)
+}
+
+func (it *encodedPostingsIterator) varint() int {
+	v, n := binary.Varint(it.data[it.offset:])
+	it.offset += n
+	return int(v)
+}
+
+func (it *encodedPostingsIterator) Next() bool {
+	if it.offset >= len(it.data) {
+		it.exhausted = true
+		return false
+	}
+	it.started = true
+	docID := it.posting.DocID + it.uvarint()
+	it.posting = Posting{DocID: docID, Freq: it.uvarint()}
+	if n := it.uvarint(); n > 0 {
+		it.posting.Occurrences = make([]Occurrence, n)
+		position, start := 0, 0
+		for i := range it.posting.Occurrences {
+			position += it.varint()
+			start += it.varint()
+			it.posting.Occurrences[i] = Occurrence{Position: position, Start: start, End: start + it.uvarint()}
+		}
+	}
+	return true
+}
+
+func (it *encodedPostingsIterator) Advance(target int) bool {
+	if it.exhausted {
+		return false
+	}
+	if it.started && it.posting.DocID >= target {
+		return true
+	}
+	// Jump to the last block that starts before target.
+	i := sort.Search(len(it.skips), func(i int) bool {
+		return it.skips[i].lastDocID >= target
+	}) - 1
+	if i >= 0 && it.skips[i].offset > it.offset {
+		it.offset = it.skips[i].offset
+		it.posting = Posting{DocID: it.skips[i].lastDocID}
+	}
+	for it.Next() {
+		if it.posting.DocID >= target {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *encodedPostingsIterator) Posting() Posting {
+	return it.posting
+}
+
+// BitmapPostings stores the documents of a posting list in a roaring
+// bitmap, with frequencies and occurrences kept in DocID order alongside.
+// It suits terms that occur in a large share of the documents, and its
+// Docs can be combined directly with set operations.
+type BitmapPostings struct {
+	docs        *Bitmap
+	freqs       []int
+	occurrences [][]Occurrence
+}
+
+func NewBitmapPostings(postings []Posting) *BitmapPostings {
+	b := &BitmapPostings{docs: NewBitmap(), freqs: make([]int, len(postings))}
+	for i, posting := range postings {
+		b.docs.Add(uint32(posting.DocID))
+		b.freqs[i] = posting.Freq
+		if len(posting.Occurrences) > 0 {
+			if b.occurrences == nil {
+				b.occurrences = make([][]Occurrence, len(postings))
+			}
+			b.occurrences[i] = posting.Occurrences
+		}
+	}
+	return b
+}
+
+func (b *BitmapPostings) Len() int {
+	return len(b.freqs)
+}
+
+// Docs returns the bitmap of the documents in the list.
+func (b *BitmapPostings) Docs() *Bitmap {
+	return b.docs
+}
+
+func (b *BitmapPostings) Iterator() PostingIterator {
+	return &bitmapPostingsIterator{postings: b, docs: b.docs.Iterator()}
+}
+
+// bitmapPostingsIterator finds the frequencies and occurrences of a
+// document by its rank in the bitmap.
+type bitmapPostingsIterator struct {
+	postings *BitmapPostings
+	docs     *BitmapIterator
+}
+
+func (it *bitmapPostingsIterator) Next() bool {
+	return it.docs.Next()
+}
+
+func (it *bitmapPostingsIterator) Advance(target int) bool {
+	if target < 0 {
+		target = 0
+	}
+	return it.docs.Advance(uint32(target))
+}
+
+func (it *bitmapPostingsIterator) Posting() Posting {
+	i := it.docs.rank()
+	posting := Posting{DocID: int(it.docs.Value()), Freq: it.postings.freqs[i]}
+	if it.postings.occurrences != nil {
+		posting.Occurrences = it.postings.occurrences[i]
+	}
+	return posting
+}
+
+// DocBitmap returns the documents of a posting list as a bitmap, building
+// one unless the list is already bitmap backed.
+func DocBitmap(list PostingList) *Bitmap {
+	if b, ok := list.(*BitmapPostings); ok {
+		return b.docs
+	}
+	docs := NewBitmap()
+	for it := list.Iterator(); it.Next(); {
+		docs.Add(uint32(it.Posting().DocID))
+	}
+	return docs
+}
+
+// IntersectPostings returns the IDs of the documents found in every list.
+// It leapfrogs through the lists starting with the shortest: each list is
+// advanced to the current candidate, and a list that overshoots supplies the
+// next candidate, so long lists are mostly skipped rather than scanned.
+func IntersectPostings(lists ...PostingList) []int {
+	if len(lists) == 0 {
+		return nil
+	}
+	sorted := append([]PostingList(nil), lists...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Len() < sorted[j].Len() })
+	iterators := make([]PostingIterator, len(sorted))
+	for i, list := range sorted {
+		iterators[i] = list.Iterator()
+	}
+
+	var docIDs []int
+	if !iterators[0].Next() {
+		return nil
+	}
+	candidate := iterators[0].Posting().DocID
+	for {
+		matched := true
+		for _, it := range iterators {
+			if !it.Advance(candidate) {
+				return docIDs
+			}
+			if docID := it.Posting().DocID; docID > candidate {
+				candidate = docID
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		docIDs = append(docIDs, candidate)
+		if !iterators[0].Next() {
+			return docIDs
+		}
+		candidate = iterators[0].Posting().DocID
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// makePostings returns postings for docIDs, each with a frequency and
+// occurrences derived from its ID.
+func makePostings(docIDs ...int) []Posting {
+	postings := make([]Posting, len(docIDs))
+	for i, docID := range docIDs {
+		freq := docID%3 + 1
+		occurrences := make([]Occurrence, freq)
+		for j := range occurrences {
+			start := docID + 10*j
+			occurrences[j] = Occurrence{Position: docID%5 + 2*j, Start: start, End: start + 3}
+		}
+		postings[i] = Posting{DocID: docID, Freq: freq, Occurrences: occurrences}
+	}
+	return postings
+}
+
+func decodeAll(list PostingList) []Posting {
+	var postings []Posting
+	for it := list.Iterator(); it.Next(); {
+		postings = append(postings, it.Posting())
+	}
+	return postings
+}
+
+func TestEncodePostingsRoundTrip(t *testing.T) {
+	many := make([]int, 1000)
+	for i := range many {
+		many[i] = i * 7
+	}
+	tests := []struct {
+		name     string
+		postings []Posting
+	}{
+		{"empty", nil},
+		{"single", makePostings(0)},
+		{"dense", makePostings(1, 2, 3, 4, 5)},
+		{"large gaps", makePostings(3, 1<<20, 1<<30)},
+		{"without occurrences", []Posting{{DocID: 4, Freq: 2}, {DocID: 9, Freq: 1}}},
+		{"several blocks", makePostings(many...)},
+	}
+	for _, tt := range tests {
+		encoded := EncodePostings(tt.postings)
+		if encoded.Len() != len(tt.postings) {
+			t.Errorf("%s: Len = %d, want %d", tt.name, encoded.Len(), len(tt.postings))
+		}
+		if got := decodeAll(encoded); !reflect.DeepEqual(got, tt.postings) {
+			t.Errorf("%s: decoded %v, want %v", tt.name, got, tt.postings)
+		}
+		if got := []Posting(encoded.Decode()); len(tt.postings) > 0 && !reflect.DeepEqual(got, tt.postings) {
+			t.Errorf("%s: Decode = %v, want %v", tt.name, got, tt.postings)
+		}
+	}
+}
+
+func TestEncodePostingsIsCompact(t *testing.T) {
+	postings := []Posting{{DocID: 1, Freq: 1}, {DocID: 2, Freq: 1}, {DocID: 130, Freq: 1}}
+	// Deltas 1, 1 and 128 take 1, 1 and 2 bytes, plus a byte each for the
+	// frequency and the number of occurrences.
+	if got := EncodePostings(postings).Size(); got != 10 {
+		t.Errorf("Size = %d, want 10", got)
+	}
+}
+
+// postingLists returns postings in every representation.
+func postingLists(postings []Posting) map[string]PostingList {
+	return map[string]PostingList{
+		"plain":   Postings(postings),
+		"encoded": EncodePostings(postings),
+		"bitmap":  NewBitmapPostings(postings),
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	var docIDs []int
+	for i := 0; i < 3000; i++ {
+		docIDs = append(docIDs, i*3)
+	}
+	// A dense run makes a bitset container.
+	for i := 70000; i < 80000; i++ {
+		docIDs = append(docIDs, i)
+	}
+	docIDs = append(docIDs, 1<<20)
+	postings := makePostings(docIDs...)
+
+	// Each step advances from where the previous one left off.
+	steps := []struct {
+		target int
+		want   int // -1 when exhausted
+	}{
+		{-5, 0},
+		{0, 0},
+		{1, 3},
+		{3, 3},
+		{200, 201},
+		{4000, 4002},
+		{4001, 4002},
+		{8998, 70000},
+		{75000, 75000},
+		{79999, 79999},
+		{80000, 1 << 20},
+		{1<<20 + 1, -1},
+	}
+	for name, list := range postingLists(postings) {
+		it := list.Iterator()
+		for _, step := range steps {
+			ok := it.Advance(step.target)
+			if step.want < 0 {
+				if ok {
+					t.Errorf("%s: Advance(%d) found %d past the end", name, step.target, it.Posting().DocID)
+				}
+				continue
+			}
+			if !ok {
+				t.Fatalf("%s: Advance(%d) found nothing, want %d", name, step.target, step.want)
+			}
+			got := it.Posting()
+			want := makePostings(step.want)[0]
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: Advance(%d) = %v, want %v", name, step.target, got, want)
+			}
+		}
+	}
+}
+
+func TestAdvanceThenNext(t *testing.T) {
+	postings := makePostings(2, 4, 6, 8, 10)
+	for name, list := range postingLists(postings) {
+		it := list.Iterator()
+		if !it.Advance(5) || it.Posting().DocID != 6 {
+			t.Fatalf("%s: Advance(5) did not stop at 6", name)
+		}
+		var rest []int
+		for it.Next() {
+			rest = append(rest, it.Posting().DocID)
+		}
+		if !reflect.DeepEqual(rest, []int{8, 10}) {
+			t.Errorf("%s: Next after Advance = %v, want [8 10]", name, rest)
+		}
+	}
+}
+
+func TestExhaustedIterator(t *testing.T) {
+	postings := makePostings(2, 4, 6)
+	for name, list := range postingLists(postings) {
+		it := list.Iterator()
+		for it.Next() {
+		}
+		for _, target := range []int{0, 6, 7} {
+			if it.Advance(target) {
+				t.Errorf("%s: Advance(%d) after the end found %d", name, target, it.Posting().DocID)
+			}
+		}
+		if it.Next() {
+			t.Errorf("%s: Next after the end found %d", name, it.Posting().DocID)
+		}
+	}
+}
+
+func TestIntersectPostings(t *testing.T) {
+	var evens, threes []int
+	for i := 0; i < 10000; i++ {
+		if i%2 == 0 {
+			evens = append(evens, i)
+		}
+		if i%3 == 0 {
+			threes = append(threes, i)
+		}
+	}
+	var want []int
+	for i := 0; i < 10000; i += 6 {
+		want = append(want, i)
+	}
+	for aName, a := range postingLists(makePostings(evens...)) {
+		for bName, b := range postingLists(makePostings(threes...)) {
+			if got := IntersectPostings(a, b); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s and %s: got %d documents, want %d", aName, bName, len(got), len(want))
+			}
+		}
+	}
+	if got := IntersectPostings(Postings(makePostings(1, 2)), Postings(nil)); got != nil {
+		t.Errorf("intersection with an empty list = %v", got)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+)
+
+// ProximityQuery boosts the documents matching Query where the terms of
+// Text, analyzed for Field, occur close together. The proximity of a
+// document is (k-1)/(w-1) for the smallest window of w positions holding
+// all the k terms of Text it contains, 1 when they are adjacent or share
+// positions; its score is multiplied by 1 + Boost * proximity. An empty
+// Field takes the closest of every indexed field. It needs the positions
+// of the index, so it leaves the scores unchanged with
+// Config.OmitPositions.
+type ProximityQuery struct {
+	Query Query
+	Field string
+	Text  string
+	Boost float64
+}
+
+func (q ProximityQuery) score(se *SearchEngine) map[int]float64 {
+	scores := q.Query.score(se)
+	proximity := make(map[int]float64)
+	fields := []string{q.Field}
+	if q.Field == "" {
+		fields = se.indexedFields()
+	}
+	for _, field := range fields {
+		for docID, p := range se.proximity(field, q.Text, scores) {
+			if p > proximity[docID] {
+				proximity[docID] = p
+			}
+		}
+	}
+	for docID, p := range proximity {
+		scores[docID] *= 1 + q.Boost*p
+	}
+	return scores
+}
+
+func (q ProximityQuery) String() string {
+	return "proximity(" + q.Query.String() + ", " + fieldPrefix(q.Field) + q.Text + ", " + strconv.FormatFloat(q.Boost, 'g', -1, 64) + ")"
+}
+
+// proximity returns the proximity of the terms of text in field for the
+// documents of docs containing at least two of them.
+func (se *SearchEngine) proximity(field, text string, docs map[int]float64) map[int]float64 {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, token := range se.queryAnalyzer(field).Analyze(text) {
+		if !seen[token.Term] {
+			seen[token.Term] = true
+			terms = append(terms, token.Term)
+		}
+	}
+	proximity := make(map[int]float64)
+	if len(terms) < 2 {
+		return proximity
+	}
+
+	// positions[docID] holds the positions of the terms in the document,
+	// tagged with the index of their term.
+	positions := make(map[int][]termPosition)
+	segments := se.searchableSegments()
+	for i, term := range terms {
+		for _, postings := range termPostings(segments, field, term) {
+			for it := postings.Iterator(); it.Next(); {
+				posting := it.Posting()
+				if _, ok := docs[posting.DocID]; !ok {
+					continue
+				}
+				for _, occurrence := range posting.Occurrences {
+					positions[posting.DocID] = append(positions[posting.DocID], termPosition{occurrence.Position, i})
+				}
+			}
+		}
+	}
+	for docID, ps := range positions {
+		if k, width := smallestWindow(ps, len(terms)); k >= 2 {
+			proximity[docID] = windowProximity(k, width)
+		}
+	}
+	return proximity
+}
+
+// windowProximity returns (k-1)/(width-1) for k terms in a window of width
+// positions, at most 1: terms sharing a position, as synonyms do, are as
+// close as adjacent ones.
+func windowProximity(k, width int) float64 {
+	if width <= k {
+		return 1
+	}
+	return float64(k-1) / float64(width-1)
+}
+
+type termPosition struct {
+	position int
+	term     int
+}
+
+// smallestWindow returns the number k of distinct terms among positions
+// and the width of the smallest window of positions holding all of them.
+func smallestWindow(positions []termPosition, numTerms int) (k, width int) {
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].position < positions[j].position
+	})
+	counts := make([]int, numTerms)
+	for _, p := range positions {
+		if counts[p.term] == 0 {
+			k++
+		}
+		counts[p.term]++
+	}
+	for i := range counts {
+		counts[i] = 0
+	}
+
+	covered := 0
+	start := 0
+	for _, p := range positions {
+		if counts[p.term] == 0 {
+			covered++
+		}
+		counts[p.term]++
+		for covered == k {
+			first := positions[start]
+			if w := p.position - first.position + 1; width == 0 || w < width {
+				width = w
+			}
+			counts[first.term]--
+			if counts[first.term] == 0 {
+				covered--
+			}
+			start++
+		}
+	}
+	return k, width
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestProximityOfSynonyms(t *testing.T) {
+	docs := []Document{
+		{ID: "a", Fields: map[string]interface{}{"body": "a red car"}},
+		{ID: "b", Fields: map[string]interface{}{"body": "car parked by an automobile"}},
+	}
+	analyzer := WithSynonyms(NewStandardAnalyzer(), SynonymMap{"car": {"automobile"}})
+	se := NewSearchEngine(docs, Config{Analyzer: analyzer})
+
+	match := MatchQuery{Field: "body", Text: "car automobile"}
+	base := make(map[string]float64)
+	for _, hit := range se.SearchQuery(match, SearchOptions{}).Hits {
+		base[hit.ID] = hit.Score
+	}
+	// car and its synonym share every position, so both documents are
+	// fully proximate.
+	result := se.SearchQuery(ProximityQuery{Query: match, Field: "body", Text: "car automobile", Boost: 1}, SearchOptions{})
+	if len(result.Hits) != 2 {
+		t.Fatalf("got %d hits, want 2", len(result.Hits))
+	}
+	for _, hit := range result.Hits {
+		if want := 2 * base[hit.ID]; math.IsNaN(hit.Score) || math.Abs(hit.Score-want) > 1e-9 {
+			t.Errorf("%s scores %v, want %v", hit.ID, hit.Score, want)
+		}
+	}
+}
+
+func TestWindowProximity(t *testing.T) {
+	tests := []struct {
+		k, width int
+		want     float64
+	}{
+		{2, 1, 1},
+		{3, 2, 1},
+		{2, 2, 1},
+		{2, 3, 0.5},
+		{3, 5, 0.5},
+	}
+	for _, tt := range tests {
+		if got := windowProximity(tt.k, tt.width); got != tt.want {
+			t.Errorf("windowProximity(%d, %d) = %v, want %v", tt.k, tt.width, got, tt.want)
+		}
+	}
+}
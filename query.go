@@ -0,0 +1,338 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Query is a node of a query tree, as built by ParseQuery or by hand, and
+// run with SearchEngine.SearchQuery.
+type Query interface {
+	// score returns the matching documents with their scores, in a map
+	// the caller may modify. se.rw must be held.
+	score(se *SearchEngine) map[int]float64
+	// String returns a readable form of the query, with + marking required,
+	// - prohibited and # filter clauses.
+	String() string
+}
+
+// MatchQuery matches the documents containing any term of Text once
+// analyzed for Field, or at least MinimumShouldMatch of the distinct terms
+// if set. An empty Field matches every indexed field and sums the scores.
+// With Coord, the score in each field is multiplied by the fraction of the
+// distinct terms the document contains. Scorer overrides the scorer of the
+// index.
+type MatchQuery struct {
+	Field              string
+	Text               string
+	MinimumShouldMatch string
+	Coord              bool
+	Scorer             Scorer
+}
+
+func (q MatchQuery) score(se *SearchEngine) map[int]float64 {
+	if q.MinimumShouldMatch == "" && !q.Coord {
+		return se.scoreFields(q.Field, func(field string) map[int]float64 {
+			return se.scoreField(field, q.Text, q.Scorer)
+		})
+	}
+	return se.scoreFields(q.Field, func(field string) map[int]float64 {
+		return se.scoreAnalyzed(field, q.Text, func(tokens []Token) map[int]float64 {
+			var clauses []map[int]float64
+			seen := make(map[string]bool)
+			for _, token := range tokens {
+				if !seen[token.Term] {
+					seen[token.Term] = true
+					clauses = append(clauses, se.scoreTokens(field, []string{token.Term}, q.Scorer))
+				}
+			}
+			scores := matchShould(clauses, minimumShouldMatch(q.MinimumShouldMatch, len(clauses), 1))
+			if q.Coord {
+				coordinate(scores, clauses, 0, len(clauses))
+			}
+			return scores
+		})
+	})
+}
+
+// scoreFields scores a query against field, or against every indexed field
+// summing the scores if field is empty.
+func (se *SearchEngine) scoreFields(field string, score func(field string) map[int]float64) map[int]float64 {
+	if field != "" {
+		return score(field)
+	}
+	scores := make(map[int]float64)
+	for _, field := range se.indexedFields() {
+		for docID, s := range score(field) {
+			scores[docID] += s
+		}
+	}
+	return scores
+}
+
+func (q MatchQuery) String() string {
+	return fieldPrefix(q.Field) + q.Text
+}
+
+// fieldPrefix returns the field: prefix of a query on field.
+func fieldPrefix(field string) string {
+	if field == "" {
+		return ""
+	}
+	return field + ":"
+}
+
+// BooleanQuery combines queries. A document matches if it matches every
+// Must clause, none of the MustNot clauses and, when there are no Must
+// clauses, at least one Should clause; its score is the sum of the scores
+// of the clauses it matches. MinimumShouldMatch overrides the number of
+// Should clauses required. Filter clauses must match too but add nothing to
+// the score, and their matches are cached. With only Filter and MustNot
+// clauses, every document matching the filters and not the MustNot clauses
+// matches with a score of zero. With Coord, the score is multiplied by the
+// fraction of the Must and Should clauses the document matches, so a
+// document matching every clause outranks one matching a single clause
+// very well.
+type BooleanQuery struct {
+	Must               []Query
+	Should             []Query
+	MustNot            []Query
+	Filter             []Query
+	MinimumShouldMatch string
+	Coord              bool
+}
+
+func (q BooleanQuery) score(se *SearchEngine) map[int]float64 {
+	var scores map[int]float64
+	for i, clause := range q.Must {
+		clauseScores := clause.score(se)
+		if i == 0 {
+			scores = clauseScores
+			continue
+		}
+		for docID, score := range scores {
+			if clauseScore, ok := clauseScores[docID]; ok {
+				scores[docID] = score + clauseScore
+			} else {
+				delete(scores, docID)
+			}
+		}
+	}
+
+	var clauses []map[int]float64
+	if len(q.Should) > 0 {
+		defaultMin := 1
+		if len(q.Must) > 0 {
+			defaultMin = 0
+		}
+		clauses = make([]map[int]float64, len(q.Should))
+		for i, clause := range q.Should {
+			clauses[i] = clause.score(se)
+		}
+		min := minimumShouldMatch(q.MinimumShouldMatch, len(clauses), defaultMin)
+		should := matchShould(clauses, min)
+		switch {
+		case scores == nil:
+			scores = should
+		case min > 0:
+			for docID, score := range scores {
+				if shouldScore, ok := should[docID]; ok {
+					scores[docID] = score + shouldScore
+				} else {
+					delete(scores, docID)
+				}
+			}
+		default:
+			for docID := range scores {
+				scores[docID] += should[docID]
+			}
+		}
+	}
+
+	for _, clause := range q.Filter {
+		docs := se.filterDocs(clause)
+		if scores == nil {
+			scores = make(map[int]float64, docs.Cardinality())
+			for it := docs.Iterator(); it.Next(); {
+				scores[int(it.Value())] = 0
+			}
+			continue
+		}
+		for docID := range scores {
+			if !docs.Contains(uint32(docID)) {
+				delete(scores, docID)
+			}
+		}
+	}
+	if scores == nil {
+		scores = se.liveDocuments()
+	}
+	if q.Coord && len(q.Must)+len(q.Should) > 0 {
+		coordinate(scores, clauses, len(q.Must), len(q.Must)+len(q.Should))
+	}
+	for _, clause := range q.MustNot {
+		for docID := range clause.score(se) {
+			delete(scores, docID)
+		}
+	}
+	return scores
+}
+
+func (q BooleanQuery) String() string {
+	var clauses []string
+	for _, clause := range q.Must {
+		clauses = append(clauses, "+"+nestedString(clause))
+	}
+	for _, clause := range q.Should {
+		clauses = append(clauses, nestedString(clause))
+	}
+	for _, clause := range q.MustNot {
+		clauses = append(clauses, "-"+nestedString(clause))
+	}
+	for _, clause := range q.Filter {
+		clauses = append(clauses, "#"+nestedString(clause))
+	}
+	return strings.Join(clauses, " ")
+}
+
+// MatchAllQuery matches every document with a score of 1, e.g. to list the
+// whole corpus, or a filtered part of it with
+// And(MatchAllQuery{}, Filter(...)).
+type MatchAllQuery struct{}
+
+func (MatchAllQuery) score(se *SearchEngine) map[int]float64 {
+	scores := se.liveDocuments()
+	for docID := range scores {
+		scores[docID] = 1
+	}
+	return scores
+}
+
+func (MatchAllQuery) String() string {
+	return "*:*"
+}
+
+// MatchNoneQuery matches no document, as a neutral default when composing
+// queries.
+type MatchNoneQuery struct{}
+
+func (MatchNoneQuery) score(se *SearchEngine) map[int]float64 {
+	return make(map[int]float64)
+}
+
+func (MatchNoneQuery) String() string {
+	return "-*:*"
+}
+
+// BoostQuery multiplies the scores of Query by Boost, to weigh it against
+// the other clauses of a query.
+type BoostQuery struct {
+	Query Query
+	Boost float64
+}
+
+func (q BoostQuery) score(se *SearchEngine) map[int]float64 {
+	scores := q.Query.score(se)
+	for docID := range scores {
+		scores[docID] *= q.Boost
+	}
+	return scores
+}
+
+func (q BoostQuery) String() string {
+	return nestedString(q.Query) + "^" + strconv.FormatFloat(q.Boost, 'g', -1, 64)
+}
+
+// minimumShouldMatch resolves a minimum number of clauses out of n: a
+// count such as "2", a percentage such as "75%", or either negated to give
+// the number of clauses that may be missing, as in "-1" or "-25%".
+// Percentages round down. An empty or invalid spec gives defaultMin.
+func minimumShouldMatch(spec string, n, defaultMin int) int {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return defaultMin
+	}
+	var min int
+	if percent := strings.TrimSuffix(spec, "%"); percent != spec {
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil {
+			return defaultMin
+		}
+		min = int(float64(n) * math.Abs(p) / 100)
+		if p < 0 {
+			min = n - min
+		}
+	} else {
+		count, err := strconv.Atoi(spec)
+		if err != nil {
+			return defaultMin
+		}
+		min = count
+		if count < 0 {
+			min = n + count
+		}
+	}
+	if min < 0 {
+		return 0
+	}
+	if min > n {
+		return n
+	}
+	return min
+}
+
+// matchShould sums the scores of clauses for the documents matching at
+// least min of them, or every document matching any of them if min is 0.
+func matchShould(clauses []map[int]float64, min int) map[int]float64 {
+	scores := make(map[int]float64)
+	matched := make(map[int]int)
+	for _, clause := range clauses {
+		for docID, score := range clause {
+			scores[docID] += score
+			matched[docID]++
+		}
+	}
+	for docID, n := range matched {
+		if n < min {
+			delete(scores, docID)
+		}
+	}
+	return scores
+}
+
+// coordinate multiplies the score of every document by the fraction of n
+// clauses it matches: the required clauses, which every document matches,
+// and the clauses containing it.
+func coordinate(scores map[int]float64, clauses []map[int]float64, required, n int) {
+	for docID := range scores {
+		matched := required
+		for _, clause := range clauses {
+			if _, ok := clause[docID]; ok {
+				matched++
+			}
+		}
+		scores[docID] *= float64(matched) / float64(n)
+	}
+}
+
+// nestedString returns the string of a query that is a clause of another,
+// in parentheses if it has clauses of its own.
+func nestedString(q Query) string {
+	if _, ok := q.(BooleanQuery); ok {
+		return "(" + q.String() + ")"
+	}
+	return q.String()
+}
+
+// liveDocuments returns every document that is not deleted, with a score
+// of zero.
+func (se *SearchEngine) liveDocuments() map[int]float64 {
+	scores := make(map[int]float64, se.numLive)
+	for docID := range se.documents {
+		if !se.isDeleted(docID) {
+			scores[docID] = 0
+		}
+	}
+	return scores
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrQuerySyntax = errors.New("query syntax error")
+
+type queryTokenKind int
+
+const (
+	queryEOF queryTokenKind = iota
+	queryWord
+	queryPhrase
+	// queryRange is a range such as [10 TO 50], brackets included.
+	queryRange
+	// queryField is the field: prefix of the clause that follows.
+	queryField
+	// queryBoost is the ^N suffix of the clause before.
+	queryBoost
+	queryAnd
+	queryOr
+	queryNot
+	queryMatchAll
+	// queryRequired and queryProhibited are the + and - prefixes of a
+	// clause.
+	queryRequired
+	queryProhibited
+	queryLeftParen
+	queryRightParen
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	text string
+	pos  int
+	// slop is the ~N suffix of a phrase.
+	slop  int
+	boost float64
+}
+
+func (t queryToken) String() string {
+	if t.kind == queryEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q at offset %d", t.text, t.pos)
+}
+
+func lexQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	start := -1
+	word := func(end int) {
+		if start < 0 {
+			return
+		}
+		token := queryToken{kind: queryWord, text: query[start:end], pos: start}
+		if i := strings.IndexByte(token.text, ':'); i > 0 && isFieldName(token.text[:i]) && !strings.HasPrefix(token.text[i+1:], "//") {
+			tokens = append(tokens, queryToken{kind: queryField, text: token.text[:i], pos: start})
+			if i == len(token.text)-1 {
+				start = -1
+				return
+			}
+			token.text, token.pos = token.text[i+1:], start+i+1
+		}
+		switch token.text {
+		case "AND":
+			token.kind = queryAnd
+		case "OR":
+			token.kind = queryOr
+		case "NOT":
+			token.kind = queryNot
+		case "*:*":
+			token.kind = queryMatchAll
+		}
+		tokens = append(tokens, token)
+		start = -1
+	}
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		switch {
+		case r == '"':
+			word(i)
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated phrase at offset %d", ErrQuerySyntax, i)
+			}
+			token := queryToken{kind: queryPhrase, text: query[i+1 : i+1+end], pos: i}
+			size = end + 2
+			if rest := query[i+size:]; strings.HasPrefix(rest, "~") {
+				digits := len(rest) - len(strings.TrimLeft(rest[1:], "0123456789")) - 1
+				slop, err := strconv.Atoi(rest[1 : 1+digits])
+				if err != nil {
+					return nil, fmt.Errorf("%w: expected a slop after \"~\" at offset %d", ErrQuerySyntax, i+size)
+				}
+				token.slop = slop
+				size += 1 + digits
+			}
+			tokens = append(tokens, token)
+		case r == '[' || r == '{':
+			word(i)
+			end := strings.IndexAny(query[i:], "]}")
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated range at offset %d", ErrQuerySyntax, i)
+			}
+			tokens = append(tokens, queryToken{kind: queryRange, text: query[i : i+end+1], pos: i})
+			size = end + 1
+		case r == '^' && boostLength(query[i+1:]) > 0:
+			word(i)
+			size = 1 + boostLength(query[i+1:])
+			boost, err := strconv.ParseFloat(query[i+1:i+size], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid boost at offset %d", ErrQuerySyntax, i)
+			}
+			tokens = append(tokens, queryToken{kind: queryBoost, text: query[i : i+size], pos: i, boost: boost})
+		case r == '(' || r == ')':
+			word(i)
+			kind := queryLeftParen
+			if r == ')' {
+				kind = queryRightParen
+			}
+			tokens = append(tokens, queryToken{kind: kind, text: string(r), pos: i})
+		case (r == '+' || r == '-') && start < 0 && i+1 < len(query) && !unicode.IsSpace(rune(query[i+1])):
+			kind := queryRequired
+			if r == '-' {
+				kind = queryProhibited
+			}
+			tokens = append(tokens, queryToken{kind: kind, text: string(r), pos: i})
+		case unicode.IsSpace(r):
+			word(i)
+		case start < 0:
+			start = i
+		}
+		i += size
+	}
+	word(len(query))
+	return append(tokens, queryToken{kind: queryEOF, pos: len(query)}), nil
+}
+
+// boostLength returns the length of the number at the start of s.
+func boostLength(s string) int {
+	return len(s) - len(strings.TrimLeft(s, "0123456789."))
+}
+
+// isFieldName reports whether the part of a word before a colon names a
+// field, rather than being part of a term such as a URL.
+func isFieldName(name string) bool {
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseQuery parses a query such as `(quick OR lazy) AND fox NOT dog`.
+// Words are matched against every indexed field unless prefixed with a
+// field name, as in title:fox, title:"brown fox" or title:(fox OR dog).
+// Quoted phrases such as "quick brown fox" match only where their words
+// appear together in order, or with a slop such as "dark night"~3 within
+// that many moves of it. Words with wildcards, such as fox* or ?og, match
+// every term they describe, and fuzzy words such as hobit~1 the terms
+// within that many edits, two if the number is left out. Numeric and date
+// fields take ranges such as price:[10 TO 50] or
+// published:[2020-01-01 TO *], with curly brackets to exclude a bound and
+// * to leave it open. A clause followed by ^N, as in fox^2 or
+// "brown fox"^3, has its score multiplied by N.
+//
+// The query *:* matches every document, so *:* -draft lists everything but
+// the drafts.
+//
+// Instead of operators, clauses can be marked required with + and
+// prohibited with -: +dog -cat fox matches the documents with dog and
+// without cat, ranking those that also have fox higher. AND binds tighter
+// than OR, and words next to each other are ORed, as in a plain query. A
+// NOT clause joined to the others without an operator excludes its
+// matches from them; a query of NOT clauses alone matches every other
+// document.
+func ParseQuery(query string) (Query, error) {
+	return parseQuery(query, "", nil)
+}
+
+// parseQuery parses a query whose words are matched against field, or
+// every indexed field if it is empty. The doc values types in fields tell
+// numeric ranges from date ranges.
+func parseQuery(query, field string, fields map[string]FieldOptions) (Query, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, field: field, fields: fields}
+	if p.peek().kind == queryEOF {
+		return nil, fmt.Errorf("%w: empty query", ErrQuerySyntax)
+	}
+	q, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind != queryEOF {
+		return nil, fmt.Errorf("%w: unexpected %s", ErrQuerySyntax, token)
+	}
+	return q, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	i      int
+	field  string
+	fields map[string]FieldOptions
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.i]
+}
+
+func (p *queryParser) next() queryToken {
+	token := p.tokens[p.i]
+	if token.kind != queryEOF {
+		p.i++
+	}
+	return token
+}
+
+func (p *queryParser) parseOr() (Query, error) {
+	var must, should, mustNot []Query
+	explicit := false
+	for {
+		occur := p.peek().kind
+		if occur == queryRequired || occur == queryProhibited {
+			p.next()
+		}
+		q, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		negation, ok := q.(BooleanQuery)
+		switch {
+		case occur == queryRequired:
+			must = append(must, q)
+		case occur == queryProhibited:
+			mustNot = append(mustNot, q)
+		case ok && isNegation(negation) && !explicit:
+			mustNot = append(mustNot, negation.MustNot...)
+		default:
+			should = append(should, q)
+		}
+
+		switch p.peek().kind {
+		case queryOr:
+			p.next()
+			explicit = true
+			continue
+		case queryWord, queryPhrase, queryRange, queryMatchAll, queryField, queryNot, queryRequired, queryProhibited, queryLeftParen:
+			explicit = false
+			continue
+		}
+		break
+	}
+	if len(should) == 1 && len(must) == 0 && len(mustNot) == 0 {
+		return should[0], nil
+	}
+	return BooleanQuery{Must: must, Should: should, MustNot: mustNot}, nil
+}
+
+func (p *queryParser) parseAnd() (Query, error) {
+	q, err := p.parseUnary()
+	if err != nil || p.peek().kind != queryAnd {
+		return q, err
+	}
+	operands := []Query{q}
+	for p.peek().kind == queryAnd {
+		p.next()
+		q, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, q)
+	}
+	return And(operands...), nil
+}
+
+func (p *queryParser) parseUnary() (Query, error) {
+	q, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind == queryBoost {
+		q = BoostQuery{Query: q, Boost: p.next().boost}
+	}
+	return q, nil
+}
+
+func (p *queryParser) parsePrimary() (Query, error) {
+	token := p.next()
+	switch token.kind {
+	case queryRequired:
+		// Inside an AND clause, + changes nothing.
+		return p.parseUnary()
+	case queryField:
+		field := p.field
+		p.field = token.text
+		q, err := p.parseUnary()
+		p.field = field
+		return q, err
+	case queryNot, queryProhibited:
+		q, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return BooleanQuery{MustNot: []Query{q}}, nil
+	case queryLeftParen:
+		q, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != queryRightParen {
+			return nil, fmt.Errorf("%w: expected \")\" for %s, got %s", ErrQuerySyntax, token, closing)
+		}
+		return q, nil
+	case queryWord:
+		if term, maxEdits, ok := parseFuzzy(token.text); ok {
+			return FuzzyQuery{Field: p.field, Term: term, MaxEdits: maxEdits}, nil
+		}
+		if isWildcard(token.text) {
+			return WildcardQuery{Field: p.field, Pattern: token.text}, nil
+		}
+		if p.fields[p.field].Type == FieldBool && (token.text == "true" || token.text == "false") {
+			return BoolFieldQuery{Field: p.field, Value: token.text == "true"}, nil
+		}
+		return MatchQuery{Field: p.field, Text: token.text}, nil
+	case queryPhrase:
+		return PhraseQuery{Field: p.field, Text: token.text, Slop: token.slop}, nil
+	case queryRange:
+		return p.parseRange(token)
+	case queryMatchAll:
+		return MatchAllQuery{}, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected %s", ErrQuerySyntax, token)
+	}
+}
+
+// parseRange parses a range such as [10 TO 50], {10 TO *] or
+// [2020-01-01 TO 2021-01-01], where square brackets include the bound and
+// curly ones exclude it. A field not known to hold dates takes a numeric
+// range if both bounds are numbers.
+func (p *queryParser) parseRange(token queryToken) (Query, error) {
+	if p.field == "" {
+		return nil, fmt.Errorf("%w: range %s needs a field", ErrQuerySyntax, token)
+	}
+	bounds := strings.Fields(token.text[1 : len(token.text)-1])
+	if len(bounds) != 3 || bounds[1] != "TO" {
+		return nil, fmt.Errorf("%w: expected a range such as [10 TO 50], got %s", ErrQuerySyntax, token)
+	}
+	excludeMin := token.text[0] == '{'
+	excludeMax := token.text[len(token.text)-1] == '}'
+
+	options := p.fields[p.field]
+	if options.DocValues != DateDocValues {
+		min, minOK := parseBound(bounds[0], math.Inf(-1))
+		max, maxOK := parseBound(bounds[2], math.Inf(1))
+		if minOK && maxOK {
+			return RangeQuery{Field: p.field, Min: min, Max: max, ExcludeMin: excludeMin, ExcludeMax: excludeMax}, nil
+		}
+		if options.DocValues == NumericDocValues {
+			return nil, fmt.Errorf("%w: invalid numeric bounds in %s", ErrQuerySyntax, token)
+		}
+	}
+	from, fromOK := parseDateBound(bounds[0], options.DateLayouts)
+	to, toOK := parseDateBound(bounds[2], options.DateLayouts)
+	if !fromOK || !toOK {
+		return nil, fmt.Errorf("%w: invalid bounds in %s", ErrQuerySyntax, token)
+	}
+	return DateRangeQuery{Field: p.field, From: from, To: to, ExcludeFrom: excludeMin, ExcludeTo: excludeMax}, nil
+}
+
+// isNegation reports whether q only excludes documents.
+func isNegation(q BooleanQuery) bool {
+	return len(q.Must) == 0 && len(q.Should) == 0 && len(q.Filter) == 0 && len(q.MustNot) > 0
+}
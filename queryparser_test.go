@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	fox := MatchQuery{Text: "fox"}
+	dog := MatchQuery{Text: "dog"}
+	cat := MatchQuery{Text: "cat"}
+	tests := []struct {
+		query string
+		want  Query
+	}{
+		{"fox", fox},
+		{"  fox  ", fox},
+		{"title:fox", MatchQuery{Field: "title", Text: "fox"}},
+		{"http://example.com", MatchQuery{Text: "http://example.com"}},
+		{"fox dog", Or(fox, dog)},
+		{"fox OR dog", Or(fox, dog)},
+		{"fox AND dog", And(fox, dog)},
+		{"fox AND dog OR cat", Or(And(fox, dog), cat)},
+		{"fox AND (dog OR cat)", And(fox, Or(dog, cat))},
+		{"fox NOT dog", BooleanQuery{Should: []Query{fox}, MustNot: []Query{dog}}},
+		{"fox AND NOT dog", BooleanQuery{Must: []Query{fox}, MustNot: []Query{dog}}},
+		{"fox OR NOT dog", Or(fox, Not(dog))},
+		{"NOT dog", Not(dog)},
+		{"title:(fox OR dog)", Or(MatchQuery{Field: "title", Text: "fox"}, MatchQuery{Field: "title", Text: "dog"})},
+		{`"brown fox"`, PhraseQuery{Text: "brown fox"}},
+		{`fox "brown dog"`, BooleanQuery{Should: []Query{fox, PhraseQuery{Text: "brown dog"}}}},
+		{"fox*", WildcardQuery{Pattern: "fox*"}},
+		{"?og", WildcardQuery{Pattern: "?og"}},
+		{"hobit~1", FuzzyQuery{Term: "hobit", MaxEdits: 1}},
+		{"hobit~", FuzzyQuery{Term: "hobit", MaxEdits: defaultMaxEdits}},
+		{`title:"brown fox"~3`, PhraseQuery{Field: "title", Text: "brown fox", Slop: 3}},
+		{"price:[10 TO 50]", RangeQuery{Field: "price", Min: 10, Max: 50}},
+		{"price:{10 TO *]", RangeQuery{Field: "price", Min: 10, Max: math.Inf(1), ExcludeMin: true}},
+		{"published:[2020-01-01 TO *}", DateRangeQuery{
+			Field:     "published",
+			From:      time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			ExcludeTo: true,
+		}},
+		{"fox^2", BoostQuery{Query: fox, Boost: 2}},
+		{`"brown fox"^1.5`, BoostQuery{Query: PhraseQuery{Text: "brown fox"}, Boost: 1.5}},
+		{"+dog -cat fox", BooleanQuery{Must: []Query{dog}, Should: []Query{fox}, MustNot: []Query{cat}}},
+		{"*:* -draft", BooleanQuery{Should: []Query{MatchAllQuery{}}, MustNot: []Query{MatchQuery{Text: "draft"}}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseQuery(%q) = %#v, want %#v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryFields(t *testing.T) {
+	fields := map[string]FieldOptions{
+		"published": {DocValues: DateDocValues, DateLayouts: []string{"2006"}},
+		"price":     {DocValues: NumericDocValues},
+		"active":    {Type: FieldBool},
+	}
+	tests := []struct {
+		query string
+		want  Query
+	}{
+		{"fox", MatchQuery{Field: "body", Text: "fox"}},
+		{"title:fox", MatchQuery{Field: "title", Text: "fox"}},
+		{"active:true", BoolFieldQuery{Field: "active", Value: true}},
+		{"active:yes", MatchQuery{Field: "active", Text: "yes"}},
+		{"published:[2020 TO 2021]", DateRangeQuery{
+			Field: "published",
+			From:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:    time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+	for _, tt := range tests {
+		got, err := parseQuery(tt.query, "body", fields)
+		if err != nil {
+			t.Errorf("parseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseQuery(%q) = %#v, want %#v", tt.query, got, tt.want)
+		}
+	}
+	if _, err := parseQuery("price:[cheap TO 50]", "", fields); !errors.Is(err, ErrQuerySyntax) {
+		t.Errorf("numeric field with a text bound: got %v, want ErrQuerySyntax", err)
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		"",
+		"   ",
+		"(fox",
+		"fox)",
+		"(fox OR dog",
+		"()",
+		"fox AND",
+		"fox OR",
+		"AND fox",
+		"NOT",
+		`"brown fox`,
+		`"brown fox"~x`,
+		"title:",
+		"[10 TO 50]",
+		"price:[10 TO 50",
+		"price:[10 50]",
+		"price:[10 TO 20 TO 30]",
+		"price:[low TO high]",
+		"fox^1.2.3",
+	} {
+		if q, err := ParseQuery(query); !errors.Is(err, ErrQuerySyntax) {
+			t.Errorf("ParseQuery(%q) = %v, %v; want ErrQuerySyntax", query, q, err)
+		}
+	}
+}
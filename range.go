@@ -0,0 +1,151 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RangeQuery matches the documents whose value of Field lies between Min
+// and Max, both included unless ExcludeMin or ExcludeMax is set. Either
+// bound may be infinite to leave that end open. The field needs numeric
+// doc values (FieldOptions.DocValues). Every match scores 1.
+type RangeQuery struct {
+	Field      string
+	Min, Max   float64
+	ExcludeMin bool
+	ExcludeMax bool
+}
+
+// NewRangeQuery returns a query for the values of field between min and
+// max inclusive.
+func NewRangeQuery(field string, min, max float64) RangeQuery {
+	return RangeQuery{Field: field, Min: min, Max: max}
+}
+
+func (q RangeQuery) contains(value float64) bool {
+	if value < q.Min || q.ExcludeMin && value == q.Min {
+		return false
+	}
+	return value < q.Max || !q.ExcludeMax && value == q.Max
+}
+
+func (q RangeQuery) score(se *SearchEngine) map[int]float64 {
+	scores := make(map[int]float64)
+	match := func(docID int) {
+		if !se.isDeleted(docID) {
+			scores[docID] = 1
+		}
+	}
+	for _, s := range se.searchableSegments() {
+		if s.points == nil {
+			for it := s.docs.Iterator(); it.Next(); {
+				docID := int(it.Value())
+				if value, ok := se.docValues.numberValue(q.Field, docID); ok && q.contains(value) {
+					match(docID)
+				}
+			}
+			continue
+		}
+		points := s.points[q.Field]
+		i := sort.Search(len(points), func(i int) bool {
+			return points[i].value >= q.Min
+		})
+		for ; i < len(points) && points[i].value <= q.Max; i++ {
+			if q.contains(points[i].value) {
+				match(points[i].docID)
+			}
+		}
+	}
+	return scores
+}
+
+func (q RangeQuery) String() string {
+	left, right := "[", "]"
+	if q.ExcludeMin {
+		left = "{"
+	}
+	if q.ExcludeMax {
+		right = "}"
+	}
+	return fieldPrefix(q.Field) + left + formatBound(q.Min) + " TO " + formatBound(q.Max) + right
+}
+
+func formatBound(bound float64) string {
+	if math.IsInf(bound, 0) {
+		return "*"
+	}
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
+
+// DateRangeQuery matches the documents whose date in Field lies between
+// From and To, both included unless ExcludeFrom or ExcludeTo is set. A zero
+// bound leaves that end open. The field needs date doc values
+// (FieldOptions.DocValues). Every match scores 1.
+type DateRangeQuery struct {
+	Field       string
+	From, To    time.Time
+	ExcludeFrom bool
+	ExcludeTo   bool
+}
+
+// rangeQuery returns the query on the Unix milliseconds the dates are
+// indexed as.
+func (q DateRangeQuery) rangeQuery() RangeQuery {
+	r := RangeQuery{
+		Field:      q.Field,
+		Min:        math.Inf(-1),
+		Max:        math.Inf(1),
+		ExcludeMin: q.ExcludeFrom,
+		ExcludeMax: q.ExcludeTo,
+	}
+	if !q.From.IsZero() {
+		r.Min = float64(q.From.UnixMilli())
+	}
+	if !q.To.IsZero() {
+		r.Max = float64(q.To.UnixMilli())
+	}
+	return r
+}
+
+func (q DateRangeQuery) score(se *SearchEngine) map[int]float64 {
+	return q.rangeQuery().score(se)
+}
+
+func (q DateRangeQuery) String() string {
+	left, right := "[", "]"
+	if q.ExcludeFrom {
+		left = "{"
+	}
+	if q.ExcludeTo {
+		right = "}"
+	}
+	return fieldPrefix(q.Field) + left + formatDate(q.From) + " TO " + formatDate(q.To) + right
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "*"
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// parseBound parses a bound of a range in the query syntax, where * leaves
+// the range open and infinity is the value to use for it.
+func parseBound(text string, infinity float64) (float64, bool) {
+	if text == "*" {
+		return infinity, true
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	return value, err == nil
+}
+
+// parseDateBound parses a bound of a date range, where * leaves the range
+// open.
+func parseDateBound(text string, layouts []string) (time.Time, bool) {
+	if text == "*" {
+		return time.Time{}, true
+	}
+	return parseDate(text, layouts)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	registryMu  sync.RWMutex
+	charFilters = map[string]CharFilter{
+		"html_strip": HTMLStripCharFilter,
+		"nfc":        NFCCharFilter,
+		"nfkc":       NFKCCharFilter,
+	}
+	tokenizers = map[string]Tokenizer{
+		"standard":   UnicodeTokenizer,
+		"whitespace": WhitespaceTokenizer,
+		"keyword":    KeywordTokenizer,
+		"cjk_bigram": CJKBigramTokenizer,
+	}
+	tokenFilters = map[string]TokenFilter{
+		"lowercase":       LowercaseFilter,
+		"asciifolding":    ASCIIFoldingFilter,
+		"english_stop":    NewStopFilter(EnglishStopWords),
+		"spanish_stop":    NewStopFilter(SpanishStopWords),
+		"german_stop":     NewStopFilter(GermanStopWords),
+		"porter_stem":     PorterStemFilter,
+		"spanish_stem":    SpanishStemFilter,
+		"german_stem":     GermanStemFilter,
+		"french_elision":  NewElisionFilter(FrenchElisions),
+		"italian_elision": NewElisionFilter(ItalianElisions),
+	}
+)
+
+// RegisterCharFilter makes a char filter available to NewAnalyzer under the
+// given name, replacing any filter registered under the same name.
+func RegisterCharFilter(name string, f CharFilter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	charFilters[name] = f
+}
+
+// RegisterTokenizer makes a tokenizer available to NewAnalyzer under the
+// given name, replacing any tokenizer registered under the same name.
+func RegisterTokenizer(name string, t Tokenizer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	tokenizers[name] = t
+}
+
+// RegisterTokenFilter makes a token filter available to NewAnalyzer under
+// the given name, replacing any filter registered under the same name.
+func RegisterTokenFilter(name string, f TokenFilter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	tokenFilters[name] = f
+}
+
+// AnalyzerConfig describes an analyzer by the registered names of its
+// components.
+type AnalyzerConfig struct {
+	CharFilters  []string
+	Tokenizer    string
+	TokenFilters []string
+}
+
+// NewAnalyzer builds the analyzer described by config. The tokenizer
+// defaults to "standard".
+func NewAnalyzer(config AnalyzerConfig) (*Analyzer, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	analyzer := &Analyzer{}
+	for _, name := range config.CharFilters {
+		f, ok := charFilters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown char filter %q", name)
+		}
+		analyzer.CharFilters = append(analyzer.CharFilters, f)
+	}
+	tokenizer := config.Tokenizer
+	if tokenizer == "" {
+		tokenizer = "standard"
+	}
+	t, ok := tokenizers[tokenizer]
+	if !ok {
+		return nil, fmt.Errorf("unknown tokenizer %q", tokenizer)
+	}
+	analyzer.Tokenizer = t
+	for _, name := range config.TokenFilters {
+		f, ok := tokenFilters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown token filter %q", name)
+		}
+		analyzer.TokenFilters = append(analyzer.TokenFilters, f)
+	}
+	return analyzer, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// defaultRerankWindow is the number of candidates a RerankQuery rescores
+// when its WindowSize is zero.
+const defaultRerankWindow = 100
+
+// RerankQuery retrieves with Query, cheaply, the WindowSize best
+// candidates (100 if zero), then scores them again with Model, such as a
+// learned ranking model; the other documents are dropped. Model gets the
+// features of a candidate: its score for Query, then its score for each of
+// Features, zero for the ones it does not match; without a Model the
+// candidates keep their scores for Query. To retrieve with BM25, wrap
+// Query in WithScorer.
+type RerankQuery struct {
+	Query      Query
+	WindowSize int
+	Features   []Query
+	Model      func(features []float64) float64
+}
+
+func (q RerankQuery) score(se *SearchEngine) map[int]float64 {
+	scores := q.Query.score(se)
+	window := q.WindowSize
+	if window <= 0 {
+		window = defaultRerankWindow
+	}
+	candidates := topDocIDs(scores, window, se.ranking(scores, nil))
+	features := make([]map[int]float64, len(q.Features))
+	for i, feature := range q.Features {
+		features[i] = feature.score(se)
+	}
+
+	reranked := make(map[int]float64, len(candidates))
+	values := make([]float64, len(features)+1)
+	for _, docID := range candidates {
+		values[0] = scores[docID]
+		if q.Model == nil {
+			reranked[docID] = values[0]
+			continue
+		}
+		for i, feature := range features {
+			values[i+1] = feature[docID]
+		}
+		reranked[docID] = q.Model(values)
+	}
+	return reranked
+}
+
+func (q RerankQuery) String() string {
+	features := make([]string, len(q.Features))
+	for i, feature := range q.Features {
+		features[i] = feature.String()
+	}
+	return "rerank(" + q.Query.String() + ", " + strconv.Itoa(q.WindowSize) + ", [" + strings.Join(features, ", ") + "])"
+}
+
+// LinearModel returns a model for RerankQuery that weighs the features by
+// weights and adds them up, missing weights counting as zero.
+func LinearModel(weights ...float64) func(features []float64) float64 {
+	return func(features []float64) float64 {
+		score := 0.0
+		for i, feature := range features {
+			if i < len(weights) {
+				score += weights[i] * feature
+			}
+		}
+		return score
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+// RewriteQuery applies fn to every node of a query tree, clauses before the
+// queries holding them, and returns the new tree. Returning its argument
+// leaves a node unchanged. For instance, to search "js" as "javascript":
+//
+//	RewriteQuery(q, func(q Query) Query {
+//		if m, ok := q.(MatchQuery); ok && m.Text == "js" {
+//			m.Text = "javascript"
+//			return m
+//		}
+//		return q
+//	})
+func RewriteQuery(q Query, fn func(Query) Query) Query {
+	switch q := q.(type) {
+	case BooleanQuery:
+		q.Must = rewriteClauses(q.Must, fn)
+		q.Should = rewriteClauses(q.Should, fn)
+		q.MustNot = rewriteClauses(q.MustNot, fn)
+		q.Filter = rewriteClauses(q.Filter, fn)
+		return fn(q)
+	case BoostQuery:
+		q.Query = RewriteQuery(q.Query, fn)
+		return fn(q)
+	case RecencyQuery:
+		q.Query = RewriteQuery(q.Query, fn)
+		return fn(q)
+	case FunctionScoreQuery:
+		q.Query = RewriteQuery(q.Query, fn)
+		return fn(q)
+	case RerankQuery:
+		q.Query = RewriteQuery(q.Query, fn)
+		return fn(q)
+	case DiversifyQuery:
+		q.Query = RewriteQuery(q.Query, fn)
+		return fn(q)
+	case ProximityQuery:
+		q.Query = RewriteQuery(q.Query, fn)
+		return fn(q)
+	case ScriptScoreQuery:
+		q.Query = RewriteQuery(q.Query, fn)
+		return fn(q)
+	}
+	return fn(q)
+}
+
+func rewriteClauses(clauses []Query, fn func(Query) Query) []Query {
+	if clauses == nil {
+		return nil
+	}
+	rewritten := make([]Query, len(clauses))
+	for i, clause := range clauses {
+		rewritten[i] = RewriteQuery(clause, fn)
+	}
+	return rewritten
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+var (
+	ErrSavedQueryNotFound = errors.New("saved query not found")
+	ErrMissingParameter   = errors.New("missing query parameter")
+)
+
+// placeholderPattern matches a {{name}} placeholder of a saved query.
+var placeholderPattern = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// savedQuery is either a query string or a query tree.
+type savedQuery struct {
+	text string
+	tree Query
+}
+
+// SaveQuery registers a query string under name, replacing any query saved
+// under it, so it can be run with RunSavedQuery. The query may hold
+// placeholders such as {{user}}, which are replaced with the parameters as
+// they are, before the query is parsed; use SaveQueryTree when the
+// parameters come from users.
+func (se *SearchEngine) SaveQuery(name, query string) {
+	se.saveQuery(name, savedQuery{text: query})
+}
+
+// SaveQueryTree registers a query tree under name. Placeholders in the
+// text or terms of its clauses are replaced with the parameters, which are
+// never parsed as query syntax.
+func (se *SearchEngine) SaveQueryTree(name string, q Query) {
+	se.saveQuery(name, savedQuery{tree: q})
+}
+
+func (se *SearchEngine) saveQuery(name string, q savedQuery) {
+	se.savedMu.Lock()
+	defer se.savedMu.Unlock()
+	if se.saved == nil {
+		se.saved = make(map[string]savedQuery)
+	}
+	se.saved[name] = q
+}
+
+// RemoveSavedQuery removes the query saved under name.
+func (se *SearchEngine) RemoveSavedQuery(name string) error {
+	se.savedMu.Lock()
+	defer se.savedMu.Unlock()
+	if _, ok := se.saved[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrSavedQueryNotFound, name)
+	}
+	delete(se.saved, name)
+	return nil
+}
+
+// SavedQueries returns the names of the saved queries.
+func (se *SearchEngine) SavedQueries() []string {
+	se.savedMu.RLock()
+	defer se.savedMu.RUnlock()
+	names := make([]string, 0, len(se.saved))
+	for name := range se.saved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SavedQuery returns the query saved under name with its placeholders
+// filled in from params.
+func (se *SearchEngine) SavedQuery(name string, params map[string]string) (Query, error) {
+	se.savedMu.RLock()
+	saved, ok := se.saved[name]
+	se.savedMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrSavedQueryNotFound, name)
+	}
+
+	if saved.tree == nil {
+		text, err := fillPlaceholders(saved.text, params)
+		if err != nil {
+			return nil, err
+		}
+		return parseQuery(text, se.defaultField, se.mapping())
+	}
+	var err error
+	q := RewriteQuery(saved.tree, func(q Query) Query {
+		fill := func(text string) string {
+			filled, fillErr := fillPlaceholders(text, params)
+			if fillErr != nil && err == nil {
+				err = fillErr
+			}
+			return filled
+		}
+		switch q := q.(type) {
+		case MatchQuery:
+			q.Text = fill(q.Text)
+			return q
+		case MultiMatchQuery:
+			q.Text = fill(q.Text)
+			return q
+		case PhraseQuery:
+			q.Text = fill(q.Text)
+			return q
+		case TermQuery:
+			q.Term = fill(q.Term)
+			return q
+		case TermsQuery:
+			terms := make([]string, len(q.Terms))
+			for i, term := range q.Terms {
+				terms[i] = fill(term)
+			}
+			q.Terms = terms
+			return q
+		case WildcardQuery:
+			q.Pattern = fill(q.Pattern)
+			return q
+		case FuzzyQuery:
+			q.Term = fill(q.Term)
+			return q
+		}
+		return q
+	})
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// RunSavedQuery runs the query saved under name with its placeholders
+// filled in from params.
+func (se *SearchEngine) RunSavedQuery(name string, params map[string]string, opts SearchOptions) (SearchResult, error) {
+	q, err := se.SavedQuery(name, params)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return se.SearchQuery(q, opts), nil
+}
+
+// fillPlaceholders replaces the {{name}} placeholders of text with params.
+func fillPlaceholders(text string, params map[string]string) (string, error) {
+	var missing string
+	filled := placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		value, ok := params[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("%w: %q", ErrMissingParameter, missing)
+	}
+	return filled, nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import "math"
+
+// Scorer is a similarity function: it scores one term of a query in one
+// document. The score of a document for several terms is the sum.
+type Scorer interface {
+	Score(term TermStats, doc DocStats, field FieldStats) float64
+}
+
+// TermStats describes a term across the index.
+type TermStats struct {
+	Term string
+	// DocFreq is the number of documents containing the term.
+	DocFreq int
+	// TotalTermFreq is the number of occurrences of the term.
+	TotalTermFreq int
+}
+
+// DocStats describes a term in a document.
+type DocStats struct {
+	DocID int
+	// Freq is the number of occurrences of the term in the field.
+	Freq int
+	// Length is the number of tokens of the field.
+	Length int
+	// Norm is the Euclidean norm of the term frequencies of the field.
+	Norm float64
+}
+
+// FieldStats describes a field across the index.
+type FieldStats struct {
+	Field string
+	// DocCount is the number of documents in the index, including deleted
+	// ones not merged away yet.
+	DocCount int
+	// AvgLength is the average number of tokens of the field.
+	AvgLength float64
+	// SumLength is the number of tokens of the field in all documents.
+	SumLength float64
+}
+
+// TFIDF scores a term by its frequency in the document times
+// ln(N / df). It is the default Scorer. With a Slope, the score is divided
+// by the pivoted length norm (1 - Slope) + Slope * dl / avgdl, which
+// penalizes fields longer than average and favors shorter ones; the larger
+// the Slope, up to 1, the stronger the penalty.
+type TFIDF struct {
+	Slope float64
+}
+
+func (s TFIDF) Score(term TermStats, doc DocStats, field FieldStats) float64 {
+	return float64(doc.Freq) * math.Log(float64(field.DocCount)/float64(term.DocFreq)) / s.norm(doc, field)
+}
+
+// norm returns the pivoted length norm of a document.
+func (s TFIDF) norm(doc DocStats, field FieldStats) float64 {
+	if s.Slope == 0 || field.AvgLength == 0 {
+		return 1
+	}
+	return 1 - s.Slope + s.Slope*float64(doc.Length)/field.AvgLength
+}
+
+// Cosine is the vector space model with cosine normalization: documents
+// and queries are vectors of tf * idf weights, and a document scores by the
+// cosine of the angle between its vector and the query's, so long
+// documents do not win by length alone. The norm of the document vector is
+// precomputed at index time from the term frequencies, as the idf of its
+// terms changes with the corpus; the norm of the query vector is left out,
+// as it is the same for every document.
+type Cosine struct{}
+
+func (Cosine) Score(term TermStats, doc DocStats, field FieldStats) float64 {
+	if doc.Norm == 0 {
+		return 0
+	}
+	idf := math.Log(float64(field.DocCount) / float64(term.DocFreq))
+	return float64(doc.Freq) * idf * idf / doc.Norm
+}
+
+// BM25 is the Okapi BM25 Scorer:
+//
+//	idf * tf * (K1 + 1) / (tf + K1 * (1 - B + B * dl / avgdl))
+//
+// where idf is ln(1 + (N - df + 0.5) / (df + 0.5)), which unlike the
+// original idf never goes negative for terms found in most documents. K1
+// controls how quickly term frequency saturates and B how much longer
+// fields are penalized; NewBM25 returns the usual values.
+type BM25 struct {
+	K1 float64
+	B  float64
+}
+
+// NewBM25 returns BM25 with K1 = 1.2 and B = 0.75.
+func NewBM25() BM25 {
+	return BM25{K1: 1.2, B: 0.75}
+}
+
+func (s BM25) Score(term TermStats, doc DocStats, field FieldStats) float64 {
+	df := float64(term.DocFreq)
+	idf := math.Log(1 + (float64(field.DocCount)-df+0.5)/(df+0.5))
+	tf := float64(doc.Freq)
+	norm := 1 - s.B
+	if field.AvgLength > 0 {
+		norm += s.B * float64(doc.Length) / field.AvgLength
+	}
+	return idf * tf * (s.K1 + 1) / (tf + s.K1*norm)
+}
+
+// WithScorer returns q with its terms scored by scorer instead of the
+// scorer of the index, e.g. to compare rankings:
+//
+//	se.SearchQuery(WithScorer(q, BM25{K1: 2, B: 0.5}), SearchOptions{})
+func WithScorer(q Query, scorer Scorer) Query {
+	return RewriteQuery(q, func(q Query) Query {
+		switch q := q.(type) {
+		case MatchQuery:
+			q.Scorer = scorer
+			return q
+		case MultiMatchQuery:
+			q.Scorer = scorer
+			return q
+		case TermQuery:
+			q.Scorer = scorer
+			return q
+		case WildcardQuery:
+			q.Scorer = scorer
+			return q
+		case FuzzyQuery:
+			q.Scorer = scorer
+			return q
+		}
+		return q
+	})
+}
+
+// termScores scores the documents containing any of tokens in field with
+// scorer, summing over the tokens.
+func (se *SearchEngine) termScores(field string, tokens []string, scorer Scorer) map[int]float64 {
+	scores := make(map[int]float64)
+	segments := se.searchableSegments()
+	fieldStats := FieldStats{
+		Field:     field,
+		DocCount:  maxDoc(segments),
+		AvgLength: se.avgFieldLength(field),
+		SumLength: se.fieldLength[field],
+	}
+
+	for _, token := range tokens {
+		lists := termPostings(segments, field, token)
+		if len(lists) == 0 {
+			continue
+		}
+		termStats := TermStats{Term: token, DocFreq: docFreq(lists), TotalTermFreq: totalTermFreq(lists)}
+		for _, postings := range lists {
+			for it := postings.Iterator(); it.Next(); {
+				posting := it.Posting()
+				if se.isDeleted(posting.DocID) {
+					continue
+				}
+				doc := DocStats{
+					DocID:  posting.DocID,
+					Freq:   posting.Freq,
+					Length: se.fieldDocLength(field, posting.DocID),
+					Norm:   se.fieldDocNorm(field, posting.DocID),
+				}
+				scores[posting.DocID] += scorer.Score(termStats, doc, fieldStats)
+			}
+		}
+	}
+
+	return scores
+}
+
+// totalTermFreq counts the occurrences of a term in its posting lists.
+func totalTermFreq(lists []PostingList) int {
+	total := 0
+	for _, postings := range lists {
+		for it := postings.Iterator(); it.Next(); {
+			total += it.Posting().Freq
+		}
+	}
+	return total
+}
+
+// LMDirichlet scores by query likelihood with Dirichlet smoothing: the
+// log of the probability of the term in the document, smoothed by its
+// probability in the whole field with the weight Mu, relative to the
+// probability of the term in the field alone:
+//
+//	ln(1 + tf / (Mu * p)) + ln(Mu / (dl + Mu))
+//
+// where p is the number of occurrences of the term over the length of the
+// field in all documents. Scores below zero count as zero. Mu is 2000 if
+// zero. It suits short queries over long documents.
+type LMDirichlet struct {
+	Mu float64
+}
+
+func (s LMDirichlet) Score(term TermStats, doc DocStats, field FieldStats) float64 {
+	mu := s.Mu
+	if mu <= 0 {
+		mu = 2000
+	}
+	p := float64(term.TotalTermFreq) / field.SumLength
+	score := math.Log(1+float64(doc.Freq)/(mu*p)) + math.Log(mu/(float64(doc.Length)+mu))
+	return math.Max(0, score)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBM25Scores(t *testing.T) {
+	docs := []Document{
+		{ID: "a", Fields: map[string]interface{}{"body": "quick fox"}},
+		{ID: "b", Fields: map[string]interface{}{"body": "quick brown fox jumps"}},
+		{ID: "c", Fields: map[string]interface{}{"body": "lazy dog sleeps"}},
+	}
+	se := NewSearchEngine(docs, Config{Scorer: NewBM25()})
+
+	// fox is in 2 of 3 documents, so idf = ln(1 + 1.5/2.5) = ln(1.6). The
+	// average length is 3, so a (length 2) scores
+	// ln(1.6) * 2.2 / (1 + 1.2 * (0.25 + 0.75 * 2/3)) and b (length 4)
+	// ln(1.6) * 2.2 / (1 + 1.2 * (0.25 + 0.75 * 4/3)).
+	want := map[string]float64{"a": 0.544215, "b": 0.413603}
+	result := se.Search("fox", SearchOptions{})
+	if len(result.Hits) != len(want) {
+		t.Fatalf("got %d hits, want %d", len(result.Hits), len(want))
+	}
+	if result.Hits[0].ID != "a" {
+		t.Errorf("first hit is %q, want the shorter a", result.Hits[0].ID)
+	}
+	for _, hit := range result.Hits {
+		if math.Abs(hit.Score-want[hit.ID]) > 1e-6 {
+			t.Errorf("%s scores %.6f, want %.6f", hit.ID, hit.Score, want[hit.ID])
+		}
+	}
+}
+
+func TestBM25Saturation(t *testing.T) {
+	term := TermStats{DocFreq: 2}
+	field := FieldStats{DocCount: 3, AvgLength: 10}
+	idf := math.Log(1.6)
+	for _, k1 := range []float64{0.5, 1.2, 2} {
+		s := BM25{K1: k1, B: 0.75}
+		last := 0.0
+		for tf := 1; tf <= 50; tf++ {
+			score := s.Score(term, DocStats{Freq: tf, Length: 10}, field)
+			if score <= last {
+				t.Errorf("K1 = %v: tf %d scores %v, not above %v", k1, tf, score, last)
+			}
+			if limit := idf * (k1 + 1); score >= limit {
+				t.Errorf("K1 = %v: tf %d scores %v, not below the limit %v", k1, tf, score, limit)
+			}
+			last = score
+		}
+	}
+
+	// The smaller K1, the sooner term frequency stops mattering.
+	gain := func(k1 float64) float64 {
+		s := BM25{K1: k1, B: 0.75}
+		one := s.Score(term, DocStats{Freq: 1, Length: 10}, field)
+		return s.Score(term, DocStats{Freq: 10, Length: 10}, field) / one
+	}
+	if gain(0.5) >= gain(1.2) || gain(1.2) >= gain(2) {
+		t.Errorf("tf 10 over tf 1: %v, %v, %v for K1 0.5, 1.2, 2; want increasing", gain(0.5), gain(1.2), gain(2))
+	}
+
+	s := BM25{K1: 0, B: 0.75}
+	for _, tf := range []int{1, 5, 100} {
+		if got := s.Score(term, DocStats{Freq: tf, Length: 10}, field); math.Abs(got-idf) > 1e-12 {
+			t.Errorf("K1 = 0: tf %d scores %v, want idf %v", tf, got, idf)
+		}
+	}
+}
+
+func TestBM25LengthNormalization(t *testing.T) {
+	term := TermStats{DocFreq: 2}
+	field := FieldStats{DocCount: 3, AvgLength: 10}
+	score := func(b float64, length int) float64 {
+		return BM25{K1: 1.2, B: b}.Score(term, DocStats{Freq: 2, Length: length}, field)
+	}
+
+	if short, long := score(0, 2), score(0, 50); short != long {
+		t.Errorf("B = 0: length 2 scores %v and length 50 %v, want equal", short, long)
+	}
+	for _, b := range []float64{0, 0.5, 1} {
+		if got, want := score(b, 10), score(0, 10); math.Abs(got-want) > 1e-12 {
+			t.Errorf("B = %v: an average length field scores %v, want %v", b, got, want)
+		}
+	}
+	// The larger B, the more a long field loses and a short one gains.
+	for _, pair := range [][2]float64{{0, 0.5}, {0.5, 1}} {
+		if score(pair[1], 30) >= score(pair[0], 30) {
+			t.Errorf("length 30 does not lose more with B = %v than with B = %v", pair[1], pair[0])
+		}
+		if score(pair[1], 3) <= score(pair[0], 3) {
+			t.Errorf("length 3 does not gain more with B = %v than with B = %v", pair[1], pair[0])
+		}
+	}
+}
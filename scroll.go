@@ -0,0 +1,52 @@
+package main
+
+// defaultScrollBatch is the number of documents a Scroll returns at a time
+// when its batch size is zero.
+const defaultScrollBatch = 1000
+
+// Scroll goes through every document matching a query in batches, in
+// indexing order, for exports. It reads from a snapshot taken when it was
+// created, so writes made meanwhile do not shift, repeat or skip documents,
+// and it only holds the set of matches, not the documents, between
+// batches. Documents are not scored.
+type Scroll struct {
+	engine    *SearchEngine
+	matches   *BitmapIterator
+	batchSize int
+}
+
+// Scroll returns a Scroll over the documents matching a query in the syntax
+// of ParseQuery, batchSize (1000 if zero) at a time.
+func (se *SearchEngine) Scroll(query string, batchSize int) *Scroll {
+	return se.ScrollQuery(se.parseSearch(query, se.defaultField), batchSize)
+}
+
+// ScrollQuery returns a Scroll over the documents matching a query tree,
+// after Config.Rewrite if set, batchSize (1000 if zero) at a time.
+func (se *SearchEngine) ScrollQuery(q Query, batchSize int) *Scroll {
+	return se.Snapshot().ScrollQuery(q, batchSize)
+}
+
+// ScrollQuery is SearchEngine.ScrollQuery against the snapshot.
+func (s *Snapshot) ScrollQuery(q Query, batchSize int) *Scroll {
+	se := s.engine
+	if se.rewrite != nil {
+		q = se.rewrite(q)
+	}
+	if batchSize <= 0 {
+		batchSize = defaultScrollBatch
+	}
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+	return &Scroll{engine: se, matches: se.matchDocs(q).Iterator(), batchSize: batchSize}
+}
+
+// Next returns the next batch of documents, or nil once every match has
+// been returned.
+func (s *Scroll) Next() []Document {
+	var batch []Document
+	for len(batch) < s.batchSize && s.matches.Next() {
+		batch = append(batch, s.engine.documents[s.matches.Value()])
+	}
+	return batch
+}
@@ -0,0 +1,157 @@
+package main
+
+import "sort"
+
+// segment is a self-contained part of the index holding the postings of a
+// subset of the documents for every field. Document IDs are global, so the
+// postings of several segments can be combined without remapping. Only the
+// in-memory buffer segment is ever modified; flushed segments are immutable
+// and are replaced as a whole when merged.
+type segment struct {
+	fields map[string]*InvertedIndex
+	// docs holds the IDs of the documents indexed in the segment, including
+	// deleted ones that have not been merged away yet.
+	docs *Bitmap
+	// points holds the numeric doc values of each field sorted by value,
+	// for range queries. It is nil in the buffer, whose values are looked
+	// up in the doc values instead.
+	points map[string][]numericPoint
+}
+
+type numericPoint struct {
+	value float64
+	docID int
+}
+
+func newSegment() *segment {
+	return &segment{fields: make(map[string]*InvertedIndex), docs: NewBitmap()}
+}
+
+func (s *segment) numDocs() int {
+	return s.docs.Cardinality()
+}
+
+// add indexes the analyzed fields of a document into a buffer segment.
+func (s *segment) add(docID int, fields map[string][]Token, positions bool) {
+	s.docs.Add(uint32(docID))
+	for field, tokens := range fields {
+		s.addField(docID, field, tokens, positions)
+	}
+}
+
+func (s *segment) addField(docID int, field string, tokens []Token, positions bool) {
+	index, ok := s.fields[field]
+	if !ok {
+		index = &InvertedIndex{}
+		s.fields[field] = index
+	}
+	for _, token := range tokens {
+		list, _ := index.Get(token.Term)
+		postings, _ := list.(Postings)
+		n := len(postings)
+		if n == 0 || postings[n-1].DocID != docID {
+			postings = append(postings, Posting{DocID: docID})
+			n++
+		}
+		postings[n-1].Freq++
+		if positions {
+			occurrence := Occurrence{Position: token.Position, Start: token.Start, End: token.End}
+			postings[n-1].Occurrences = append(postings[n-1].Occurrences, occurrence)
+		}
+		index.Put(token.Term, postings)
+	}
+}
+
+// postings returns the posting list of a term in a field.
+func (s *segment) postings(field, term string) (PostingList, bool) {
+	return s.fields[field].Get(term)
+}
+
+// freeze returns an immutable copy of a buffer segment with its posting
+// lists stored in the representation chosen by options and its numeric doc
+// values sorted.
+func (s *segment) freeze(values docValues, options IndexOptions) *segment {
+	return rebuildSegment([]*segment{s}, nil, values, options)
+}
+
+// mergeSegments combines segments into one, dropping the postings of
+// deleted documents.
+func mergeSegments(segments []*segment, deleted *Bitmap, values docValues, options IndexOptions) *segment {
+	return rebuildSegment(segments, deleted, values, options)
+}
+
+func rebuildSegment(segments []*segment, deleted *Bitmap, values docValues, options IndexOptions) *segment {
+	docs := NewBitmap()
+	for _, s := range segments {
+		docs = docs.Or(s.docs)
+	}
+	if deleted != nil {
+		docs = docs.AndNot(deleted)
+	}
+	numDocs := docs.Cardinality()
+
+	merged := make(map[string]map[string][]Posting)
+	for _, s := range segments {
+		for field, index := range s.fields {
+			terms, ok := merged[field]
+			if !ok {
+				terms = make(map[string][]Posting)
+				merged[field] = terms
+			}
+			index.Walk(func(term string, list PostingList) bool {
+				for it := list.Iterator(); it.Next(); {
+					posting := it.Posting()
+					if deleted != nil && deleted.Contains(uint32(posting.DocID)) {
+						continue
+					}
+					terms[term] = append(terms[term], posting)
+				}
+				return true
+			})
+		}
+	}
+
+	result := &segment{
+		fields: make(map[string]*InvertedIndex),
+		docs:   docs,
+		points: make(map[string][]numericPoint),
+	}
+	for field, terms := range merged {
+		index := &InvertedIndex{}
+		for term, postings := range terms {
+			index.Put(term, encodePostingList(normalizePostings(postings), numDocs, options))
+		}
+		result.fields[field] = index
+	}
+	for field := range values.numeric {
+		var points []numericPoint
+		for it := docs.Iterator(); it.Next(); {
+			docID := int(it.Value())
+			if value, ok := values.numberValue(field, docID); ok {
+				points = append(points, numericPoint{value: value, docID: docID})
+			}
+		}
+		sort.Slice(points, func(i, j int) bool {
+			if points[i].value != points[j].value {
+				return points[i].value < points[j].value
+			}
+			return points[i].docID < points[j].docID
+		})
+		if len(points) > 0 {
+			result.points[field] = points
+		}
+	}
+	return result
+}
+
+// encodePostingList picks the representation of a posting list.
+func encodePostingList(postings []Posting, numDocs int, options IndexOptions) PostingList {
+	switch {
+	case options.BitmapThreshold > 0 && float64(len(postings)) > options.BitmapThreshold*float64(numDocs):
+		return NewBitmapPostings(postings)
+	case options.Compress:
+		return EncodePostings(postings)
+	default:
+		return Postings(postings)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMergeSegmentsDropsDeleted(t *testing.T) {
+	analyzer := NewStandardAnalyzer()
+	values := newDocValues()
+	var segments []*segment
+	for _, docs := range []map[int]string{
+		{0: "red apple", 1: "green apple", 2: "red cherry"},
+		{3: "green pear", 4: "red pear", 5: "yellow lemon"},
+	} {
+		buffer := newSegment()
+		for docID, text := range docs {
+			buffer.add(docID, map[string][]Token{"body": analyzer.Analyze(text)}, true)
+			values.setNumeric("price", docID, float64(10-docID))
+		}
+		segments = append(segments, buffer.freeze(values.clone(), IndexOptions{Positions: true}))
+	}
+
+	merged := mergeSegments(segments, NewBitmap(1, 4, 5), values, IndexOptions{Positions: true})
+	if got, want := merged.docs.ToArray(), []uint32{0, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("docs = %v, want %v", got, want)
+	}
+	tests := []struct {
+		term string
+		want []int
+	}{
+		{"red", []int{0, 2}},
+		{"green", []int{3}},
+		{"apple", []int{0}},
+		{"pear", []int{3}},
+		{"lemon", nil},
+		{"yellow", nil},
+	}
+	for _, tt := range tests {
+		list, ok := merged.postings("body", tt.term)
+		if ok != (tt.want != nil) {
+			t.Errorf("postings(%q) found = %v, want %v", tt.term, ok, tt.want != nil)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		var got []int
+		for _, posting := range decodeAll(list) {
+			got = append(got, posting.DocID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("postings(%q) = %v, want %v", tt.term, got, tt.want)
+		}
+	}
+	want := []numericPoint{{value: 7, docID: 3}, {value: 8, docID: 2}, {value: 10, docID: 0}}
+	if got := merged.points["price"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("points = %v, want %v", got, want)
+	}
+}
+
+func TestMergedPostingsIncrease(t *testing.T) {
+	analyzer := NewStandardAnalyzer()
+	var segments []*segment
+	// Interleaved IDs, so every merged list has to be put back in order.
+	for _, docIDs := range [][]int{{4, 0, 8}, {1, 7, 3}, {6, 2, 5}} {
+		buffer := newSegment()
+		for _, docID := range docIDs {
+			buffer.add(docID, map[string][]Token{"body": analyzer.Analyze("fox fox dog")}, true)
+		}
+		segments = append(segments, buffer.freeze(newDocValues(), IndexOptions{Positions: true}))
+	}
+
+	for _, options := range []IndexOptions{
+		{Positions: true},
+		{Positions: true, Compress: true},
+		{Positions: true, BitmapThreshold: 0.5},
+	} {
+		merged := mergeSegments(segments, NewBitmap(3), newDocValues(), options)
+		for _, term := range []string{"fox", "dog"} {
+			list, ok := merged.postings("body", term)
+			if !ok {
+				t.Fatalf("%+v: no postings for %q", options, term)
+			}
+			last := -1
+			n := 0
+			for it := list.Iterator(); it.Next(); {
+				posting := it.Posting()
+				if posting.DocID <= last {
+					t.Errorf("%+v: %q: doc %d after doc %d", options, term, posting.DocID, last)
+				}
+				if posting.DocID == 3 {
+					t.Errorf("%+v: %q: deleted doc 3 kept", options, term)
+				}
+				last = posting.DocID
+				n++
+			}
+			if n != 8 {
+				t.Errorf("%+v: %q has %d postings, want 8", options, term, n)
+			}
+		}
+	}
+}
+
+func TestEngineMergesAwayDeletedDocuments(t *testing.T) {
+	se := NewSearchEngine(nil, Config{FlushThreshold: 2, MergeFactor: 2})
+	var want []string
+	for i := 0; i < 12; i++ {
+		doc := Document{ID: fmt.Sprint(i), Fields: map[string]interface{}{"body": fmt.Sprintf("common term%d", i)}}
+		if err := se.AddDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+		if i%3 == 0 {
+			if err := se.RemoveDocument(doc.ID); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			want = append(want, doc.ID)
+		}
+	}
+	sort.Strings(want)
+
+	check := func(stage string) {
+		result := se.Search("common", SearchOptions{Size: 100})
+		var got []string
+		for _, hit := range result.Hits {
+			got = append(got, hit.ID)
+		}
+		sort.Strings(got)
+		if result.Total != len(want) || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: found %v (total %d), want %v", stage, got, result.Total, want)
+		}
+		if result := se.Search("term3", SearchOptions{}); result.Total != 0 {
+			t.Errorf("%s: deleted document 3 found", stage)
+		}
+	}
+	se.merges.Wait()
+	check("after background merges")
+
+	se.ForceMerge()
+	check("after ForceMerge")
+	if len(se.segments) != 1 {
+		t.Fatalf("%d segments after ForceMerge, want 1", len(se.segments))
+	}
+	if n := se.segments[0].numDocs(); n != len(want) {
+		t.Errorf("merged segment has %d documents, want %d", n, len(want))
+	}
+	if _, ok := se.segments[0].postings("body", "term3"); ok {
+		t.Error("merged segment kept the postings of deleted document 3")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import "sort"
+
+// defaultMinDocCount is the number of matching documents a significant
+// term must occur in without SignificantTerms.MinDocCount.
+const defaultMinDocCount = 3
+
+// SignificantTerms finds the terms of Field, or of Config.DefaultField if
+// empty, that are unusually frequent in the matching documents compared to
+// the whole index, such as the related topics of a query. Terms are scored by JLH,
+// (fg - bg) * fg / bg, where fg and bg are the fractions of the matching
+// and of all documents containing them; the Size best (10 if zero) among
+// those in at least MinDocCount matching documents (3 if zero) are kept.
+type SignificantTerms struct {
+	Field       string
+	Size        int
+	MinDocCount int
+}
+
+// SignificantTerm is a term found by SignificantTerms, with the number of
+// matching documents and of all documents containing it.
+type SignificantTerm struct {
+	Term     string
+	Score    float64
+	DocCount int
+	BgCount  int
+}
+
+// significantTerms scores the terms of the documents of scores by st.
+// Every term of the field is read, so it costs as much as a pass over its
+// postings.
+func (se *SearchEngine) significantTerms(scores map[int]float64, st SignificantTerms) []SignificantTerm {
+	field := st.Field
+	if field == "" {
+		field = se.defaultField
+	}
+	size := st.Size
+	if size <= 0 {
+		size = defaultSize
+	}
+	minDocCount := st.MinDocCount
+	if minDocCount <= 0 {
+		minDocCount = defaultMinDocCount
+	}
+	if field == "" || len(scores) == 0 || se.numLive == 0 {
+		return nil
+	}
+
+	fgCount := make(map[string]int)
+	bgCount := make(map[string]int)
+	for _, s := range se.searchableSegments() {
+		s.fields[field].Walk(func(term string, postings PostingList) bool {
+			for it := postings.Iterator(); it.Next(); {
+				docID := it.Posting().DocID
+				if se.isDeleted(docID) {
+					continue
+				}
+				bgCount[term]++
+				if _, ok := scores[docID]; ok {
+					fgCount[term]++
+				}
+			}
+			return true
+		})
+	}
+
+	var terms []SignificantTerm
+	for term, count := range fgCount {
+		if count < minDocCount {
+			continue
+		}
+		fg := float64(count) / float64(len(scores))
+		bg := float64(bgCount[term]) / float64(se.numLive)
+		if fg <= bg {
+			continue
+		}
+		terms = append(terms, SignificantTerm{term, (fg - bg) * fg / bg, count, bgCount[term]})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Score != terms[j].Score {
+			return terms[i].Score > terms[j].Score
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if len(terms) > size {
+		terms = terms[:size]
+	}
+	return terms
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"sort"
+)
+
+// Dedup suppresses near-duplicate results: of documents whose SimHash
+// fingerprints differ in at most Distance of their 64 bits, only the best
+// is kept. Fingerprints are taken from the indexed terms of every field, so
+// a Distance of 0 drops the documents with the same terms as a better one,
+// and a Distance of about 3 also catches small edits.
+type Dedup struct {
+	Distance int
+}
+
+// simHash returns the SimHash fingerprint of the terms of fields, each
+// term weighted by its frequency.
+func simHash(fields map[string][]Token) uint64 {
+	var weights [64]int
+	for _, tokens := range fields {
+		for _, token := range tokens {
+			h := fnv.New64a()
+			h.Write([]byte(token.Term))
+			sum := h.Sum64()
+			for bit := range weights {
+				if sum&(1<<bit) != 0 {
+					weights[bit]++
+				} else {
+					weights[bit]--
+				}
+			}
+		}
+	}
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// dedup returns the documents of scores that are not near-duplicates of a
+// better one in the order of better, with their scores.
+//
+// Fingerprints within distance bits of each other agree on at least one of
+// distance+1 bands of bits, so each kept document is looked up by band and
+// only the documents sharing a band with it are compared.
+func (se *SearchEngine) dedup(scores map[int]float64, distance int, better func(a, b int) bool) map[int]float64 {
+	docIDs := make([]int, 0, len(scores))
+	for docID := range scores {
+		docIDs = append(docIDs, docID)
+	}
+	sort.Slice(docIDs, func(i, j int) bool {
+		return better(docIDs[i], docIDs[j])
+	})
+
+	numBands := distance + 1
+	if numBands > 64 {
+		numBands = 64
+	}
+	if numBands < 1 {
+		numBands = 1
+	}
+	width := 64 / numBands
+	bands := make([]map[uint64][]uint64, numBands)
+	for i := range bands {
+		bands[i] = make(map[uint64][]uint64)
+	}
+	band := func(fingerprint uint64, i int) uint64 {
+		if i == numBands-1 {
+			return fingerprint >> (i * width)
+		}
+		return fingerprint >> (i * width) & (1<<width - 1)
+	}
+
+	kept := make(map[int]float64)
+	for _, docID := range docIDs {
+		fingerprint := se.fingerprints[docID]
+		duplicate := false
+		for i := 0; i < numBands && !duplicate; i++ {
+			for _, other := range bands[i][band(fingerprint, i)] {
+				if bits.OnesCount64(fingerprint^other) <= distance {
+					duplicate = true
+					break
+				}
+			}
+		}
+		if duplicate {
+			continue
+		}
+		kept[docID] = scores[docID]
+		for i := range bands {
+			key := band(fingerprint, i)
+			bands[i][key] = append(bands[i][key], fingerprint)
+		}
+	}
+	return kept
+}
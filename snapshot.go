@@ -0,0 +1,102 @@
+package main
+
+import "sync/atomic"
+
+// Snapshot is a point-in-time view of a SearchEngine. It keeps seeing the
+// documents as they were when it was taken, whatever is indexed or deleted
+// afterwards, so paging through results or exporting the documents gives a
+// consistent answer. A Snapshot is safe for concurrent use.
+type Snapshot struct {
+	engine *SearchEngine
+}
+
+// Snapshot returns a view of the current state of the index. Flushed
+// segments are immutable and shared with the engine, and so are the
+// document table and the ID map until the engine next writes, when it
+// copies them; the buffer and the tombstones are copied.
+func (se *SearchEngine) Snapshot() *Snapshot {
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+
+	atomic.StoreInt32(&se.shared, 1)
+	n := len(se.documents)
+	view := &SearchEngine{
+		documents:      se.documents[:n:n],
+		ids:            se.ids,
+		analyzer:       se.analyzer,
+		searchAnalyzer: se.searchAnalyzer,
+		fields:         se.fields,
+		defaultField:   se.defaultField,
+		languages:      se.languages,
+		rewrite:        se.rewrite,
+		tieBreak:       se.tieBreak,
+		coord:          se.coord,
+		indexOptions:   se.indexOptions,
+		flushThreshold: se.flushThreshold,
+		mergeFactor:    se.mergeFactor,
+		buffer:         newSegment(),
+		numLive:        se.numLive,
+		fieldLength:    make(map[string]float64, len(se.fieldLength)),
+		docLength:      make(map[string][]int, len(se.docLength)),
+		docNorm:        make(map[string][]float64, len(se.docNorm)),
+		fingerprints:   se.fingerprints,
+		docValues:      se.docValues.clone(),
+		scorer:         se.scorer,
+	}
+	for field, length := range se.fieldLength {
+		view.fieldLength[field] = length
+	}
+	// Lengths are only ever written past the end of these slices, which the
+	// snapshot does not see.
+	for field, lengths := range se.docLength {
+		view.docLength[field] = lengths
+	}
+	for field, norms := range se.docNorm {
+		view.docNorm[field] = norms
+	}
+
+	se.mu.Lock()
+	view.segments = append([]*segment(nil), se.segments...)
+	if se.buffer.numDocs() > 0 {
+		view.segments = append(view.segments, se.buffer.freeze(view.docValues, se.indexOptions))
+	}
+	view.deleted = se.deleted.Or(NewBitmap())
+	se.mu.Unlock()
+
+	return &Snapshot{engine: view}
+}
+
+// Search is SearchEngine.Search against the snapshot.
+func (s *Snapshot) Search(query string, opts SearchOptions) SearchResult {
+	return s.engine.Search(query, opts)
+}
+
+// SearchField is SearchEngine.SearchField against the snapshot.
+func (s *Snapshot) SearchField(field, query string, opts SearchOptions) SearchResult {
+	return s.engine.SearchField(field, query, opts)
+}
+
+// SearchQuery is SearchEngine.SearchQuery against the snapshot.
+func (s *Snapshot) SearchQuery(q Query, opts SearchOptions) SearchResult {
+	return s.engine.SearchQuery(q, opts)
+}
+
+// Document returns the document with the given ID.
+func (s *Snapshot) Document(id string) (Document, bool) {
+	docID, ok := s.engine.ids[id]
+	if !ok {
+		return Document{}, false
+	}
+	return s.engine.documents[docID], true
+}
+
+// Documents returns every document in the snapshot in indexing order.
+func (s *Snapshot) Documents() []Document {
+	documents := make([]Document, 0, s.engine.numLive)
+	for docID, doc := range s.engine.documents {
+		if !s.engine.isDeleted(docID) {
+			documents = append(documents, doc)
+		}
+	}
+	return documents
+}
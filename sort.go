@@ -0,0 +1,97 @@
+package main
+
+// SortField orders results by the value of Field: its numeric or date doc
+// value, else its keyword doc value, else its stored text. Documents
+// without a value come last in either direction.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ranking returns the order of the results of a search: by the sort
+// fields, then by score, then by breakTie.
+func (se *SearchEngine) ranking(scores map[int]float64, sortFields []SortField) func(a, b int) bool {
+	return func(a, b int) bool {
+		for _, s := range sortFields {
+			if c := se.compareField(s.Field, s.Desc, a, b); c != 0 {
+				return c < 0
+			}
+		}
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return se.breakTie(a, b)
+	}
+}
+
+// compareField compares the values of field in documents a and b, in
+// descending order if desc, returning a negative number if a comes first,
+// a positive one if b does, and zero if they are equal.
+func (se *SearchEngine) compareField(field string, desc bool, a, b int) int {
+	return compareSortKeys(se.sortKey(field, a), se.sortKey(field, b), desc)
+}
+
+// sortKey is the value a document is sorted by on a field: its number if
+// it has a numeric or date doc value, else its text.
+type sortKey struct {
+	number  float64
+	numeric bool
+	text    string
+}
+
+func (se *SearchEngine) sortKey(field string, docID int) sortKey {
+	if n, ok := se.docValues.numberValue(field, docID); ok {
+		return sortKey{number: n, numeric: true}
+	}
+	return sortKey{text: se.sortText(field, docID)}
+}
+
+// sortKeys returns the keys of a document on the sort fields, then on the
+// tie-break field if there is one, for cursors.
+func (se *SearchEngine) sortKeys(docID int, sortFields []SortField) []sortKey {
+	keys := make([]sortKey, 0, len(sortFields)+1)
+	for _, s := range sortFields {
+		keys = append(keys, se.sortKey(s.Field, docID))
+	}
+	if se.tieBreak != "" {
+		keys = append(keys, se.sortKey(se.tieBreak, docID))
+	}
+	return keys
+}
+
+// compareSortKeys compares two keys like compareField. Numbers come before
+// texts, and documents without a value last.
+func compareSortKeys(x, y sortKey, desc bool) int {
+	if x.numeric || y.numeric {
+		switch {
+		case !y.numeric:
+			return -1
+		case !x.numeric:
+			return 1
+		case x.number == y.number:
+			return 0
+		case x.number < y.number != desc:
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case x.text == y.text:
+		return 0
+	case y.text == "":
+		return -1
+	case x.text == "":
+		return 1
+	case x.text < y.text != desc:
+		return -1
+	}
+	return 1
+}
+
+// sortText returns the text a document is sorted by on field.
+func (se *SearchEngine) sortText(field string, docID int) string {
+	if value, ok := se.docValues.keywordValue(field, docID); ok {
+		return value
+	}
+	return se.documents[docID].Field(field)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SpanQuery is a query that matches spans of positions in a document
+// field, which span queries such as SpanNearQuery build on. Span queries
+// need the positions of the index, so they match nothing with
+// Config.OmitPositions. A document scores by the sum of the idf of the
+// terms of the query times its span frequency, where a span with gaps
+// counts as 1/(gaps+1).
+type SpanQuery interface {
+	Query
+	// spans returns the spans of every matching document, sorted by start.
+	spans(se *SearchEngine) map[int][]span
+	// terms returns the terms the query is made of.
+	terms() []SpanTermQuery
+}
+
+// span is the positions [start, end) of a match, with the number of
+// positions left between its parts.
+type span struct {
+	start, end int
+	gaps       int
+}
+
+// spanScores scores the documents matching q.
+func (se *SearchEngine) spanScores(q SpanQuery) map[int]float64 {
+	segments := se.searchableSegments()
+	numDocs := maxDoc(segments)
+	idf := 0.0
+	seen := make(map[SpanTermQuery]bool)
+	for _, term := range q.terms() {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		if df := docFreq(termPostings(segments, term.Field, term.Term)); df > 0 {
+			idf += math.Log(float64(numDocs) / float64(df))
+		}
+	}
+	scores := make(map[int]float64)
+	for docID, spans := range q.spans(se) {
+		freq := 0.0
+		for _, s := range spans {
+			freq += 1 / float64(s.gaps+1)
+		}
+		scores[docID] = freq * idf
+	}
+	return scores
+}
+
+// SpanTermQuery matches the positions of Term in Field, taken exactly as
+// given like TermQuery. Field must be set.
+type SpanTermQuery struct {
+	Field string
+	Term  string
+}
+
+func (q SpanTermQuery) spans(se *SearchEngine) map[int][]span {
+	spans := make(map[int][]span)
+	for _, postings := range termPostings(se.searchableSegments(), q.Field, q.Term) {
+		for it := postings.Iterator(); it.Next(); {
+			posting := it.Posting()
+			if se.isDeleted(posting.DocID) {
+				continue
+			}
+			for _, occurrence := range posting.Occurrences {
+				spans[posting.DocID] = append(spans[posting.DocID], span{start: occurrence.Position, end: occurrence.Position + 1})
+			}
+		}
+	}
+	return spans
+}
+
+func (q SpanTermQuery) terms() []SpanTermQuery {
+	return []SpanTermQuery{q}
+}
+
+func (q SpanTermQuery) score(se *SearchEngine) map[int]float64 {
+	return se.spanScores(q)
+}
+
+func (q SpanTermQuery) String() string {
+	return fieldPrefix(q.Field) + q.Term
+}
+
+// SpanNearQuery matches the spans made of one span of every clause, none
+// overlapping, with at most Slop positions between them in all. With
+// InOrder the spans must come in the order of the clauses.
+type SpanNearQuery struct {
+	Clauses []SpanQuery
+	Slop    int
+	InOrder bool
+}
+
+func (q SpanNearQuery) spans(se *SearchEngine) map[int][]span {
+	if len(q.Clauses) == 0 {
+		return make(map[int][]span)
+	}
+	clauses := make([]map[int][]span, len(q.Clauses))
+	for i, clause := range q.Clauses {
+		clauses[i] = clause.spans(se)
+	}
+	spans := make(map[int][]span)
+	for docID := range clauses[0] {
+		parts := make([][]span, len(clauses))
+		matched := true
+		for i, clause := range clauses {
+			parts[i] = clause[docID]
+			matched = matched && len(parts[i]) > 0
+		}
+		if !matched {
+			continue
+		}
+		if matches := q.nearSpans(parts); len(matches) > 0 {
+			spans[docID] = matches
+		}
+	}
+	return spans
+}
+
+// nearSpans returns the matches within one document, given the spans of
+// every clause sorted by start. Each match is the closest one starting with
+// some span of a clause: in order, every following clause takes its first
+// span after the previous one; otherwise the spans are walked the way a
+// k-way merge walks sorted lists, always moving the clause that is
+// furthest behind, as for sloppy phrases.
+func (q SpanNearQuery) nearSpans(parts [][]span) []span {
+	found := make(map[span]bool)
+	if q.InOrder {
+		q.orderedSpans(parts, found)
+	} else {
+		q.unorderedSpans(parts, found)
+	}
+	matches := make([]span, 0, len(found))
+	for s := range found {
+		matches = append(matches, s)
+	}
+	sortSpans(matches)
+	return matches
+}
+
+func (q SpanNearQuery) orderedSpans(parts [][]span, found map[span]bool) {
+	for _, first := range parts[0] {
+		end, length, gaps := first.end, first.end-first.start, first.gaps
+		matched := true
+		for _, spans := range parts[1:] {
+			k := sort.Search(len(spans), func(k int) bool { return spans[k].start >= end })
+			if k == len(spans) {
+				matched = false
+				break
+			}
+			s := spans[k]
+			end, length, gaps = s.end, length+s.end-s.start, gaps+s.gaps
+		}
+		if g := end - first.start - length + gaps; matched && g <= q.Slop {
+			found[span{start: first.start, end: end, gaps: g}] = true
+		}
+	}
+}
+
+func (q SpanNearQuery) unorderedSpans(parts [][]span, found map[span]bool) {
+	cursors := make(spanCursors, len(parts))
+	for i, spans := range parts {
+		cursors[i] = &spanCursor{spans: spans}
+	}
+	heap.Init(&cursors)
+
+	chosen := make([]span, 0, len(parts))
+	for {
+		chosen = chosen[:0]
+		start, end, length, gaps := cursors[0].span().start, 0, 0, 0
+		disjoint := true
+		for _, c := range cursors {
+			s := c.span()
+			disjoint = disjoint && !overlaps(chosen, s)
+			chosen = append(chosen, s)
+			if s.end > end {
+				end = s.end
+			}
+			length += s.end - s.start
+			gaps += s.gaps
+		}
+		if g := end - start - length + gaps; disjoint && g <= q.Slop {
+			found[span{start: start, end: end, gaps: g}] = true
+		}
+		first := cursors[0]
+		first.i++
+		if first.i == len(first.spans) {
+			return
+		}
+		heap.Fix(&cursors, 0)
+	}
+}
+
+type spanCursor struct {
+	spans []span
+	i     int
+}
+
+func (c *spanCursor) span() span {
+	return c.spans[c.i]
+}
+
+// spanCursors is a min-heap of cursors by span start, then end.
+type spanCursors []*spanCursor
+
+func (h spanCursors) Len() int            { return len(h) }
+func (h spanCursors) Less(i, j int) bool  { return spanLess(h[i].span(), h[j].span()) }
+func (h spanCursors) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *spanCursors) Push(x interface{}) { *h = append(*h, x.(*spanCursor)) }
+func (h *spanCursors) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func (q SpanNearQuery) terms() []SpanTermQuery {
+	return spanTerms(q.Clauses)
+}
+
+func (q SpanNearQuery) score(se *SearchEngine) map[int]float64 {
+	return se.spanScores(q)
+}
+
+func (q SpanNearQuery) String() string {
+	return "spanNear(" + spanStrings(q.Clauses) + ", " + strconv.Itoa(q.Slop) + ", " + strconv.FormatBool(q.InOrder) + ")"
+}
+
+// SpanOrQuery matches the spans of any of its clauses.
+type SpanOrQuery struct {
+	Clauses []SpanQuery
+}
+
+func (q SpanOrQuery) spans(se *SearchEngine) map[int][]span {
+	spans := make(map[int][]span)
+	for _, clause := range q.Clauses {
+		for docID, clauseSpans := range clause.spans(se) {
+			spans[docID] = append(spans[docID], clauseSpans...)
+		}
+	}
+	for _, docSpans := range spans {
+		sortSpans(docSpans)
+	}
+	return spans
+}
+
+func (q SpanOrQuery) terms() []SpanTermQuery {
+	return spanTerms(q.Clauses)
+}
+
+func (q SpanOrQuery) score(se *SearchEngine) map[int]float64 {
+	return se.spanScores(q)
+}
+
+func (q SpanOrQuery) String() string {
+	return "spanOr(" + spanStrings(q.Clauses) + ")"
+}
+
+// SpanNotQuery matches the spans of Include that overlap no span of
+// Exclude, such as "new" not followed by "york" with
+// SpanNotQuery{Include: new, Exclude: spanNear([new, york], 0, true)}.
+type SpanNotQuery struct {
+	Include SpanQuery
+	Exclude SpanQuery
+}
+
+func (q SpanNotQuery) spans(se *SearchEngine) map[int][]span {
+	spans := q.Include.spans(se)
+	excluded := q.Exclude.spans(se)
+	for docID, docSpans := range spans {
+		kept := docSpans[:0]
+		for _, s := range docSpans {
+			if !overlaps(excluded[docID], s) {
+				kept = append(kept, s)
+			}
+		}
+		if len(kept) == 0 {
+			delete(spans, docID)
+		} else {
+			spans[docID] = kept
+		}
+	}
+	return spans
+}
+
+func (q SpanNotQuery) terms() []SpanTermQuery {
+	return q.Include.terms()
+}
+
+func (q SpanNotQuery) score(se *SearchEngine) map[int]float64 {
+	return se.spanScores(q)
+}
+
+func (q SpanNotQuery) String() string {
+	return "spanNot(" + q.Include.String() + ", " + q.Exclude.String() + ")"
+}
+
+// overlaps reports whether s overlaps any of spans.
+func overlaps(spans []span, s span) bool {
+	for _, other := range spans {
+		if s.start < other.end && other.start < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+func sortSpans(spans []span) {
+	sort.Slice(spans, func(i, j int) bool {
+		return spanLess(spans[i], spans[j])
+	})
+}
+
+// spanLess orders spans by start, then end.
+func spanLess(a, b span) bool {
+	if a.start != b.start {
+		return a.start < b.start
+	}
+	return a.end < b.end
+}
+
+func spanTerms(clauses []SpanQuery) []SpanTermQuery {
+	var terms []SpanTermQuery
+	for _, clause := range clauses {
+		terms = append(terms, clause.terms()...)
+	}
+	return terms
+}
+
+func spanStrings(clauses []SpanQuery) string {
+	strs := make([]string, len(clauses))
+	for i, clause := range clauses {
+		strs[i] = clause.String()
+	}
+	return "[" + strings.Join(strs, ", ") + "]"
+}
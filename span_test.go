@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpanNearQuery(t *testing.T) {
+	docs := []Document{
+		{ID: "a", Fields: map[string]interface{}{"body": "quick brown fox jumps over lazy dog"}},
+		{ID: "b", Fields: map[string]interface{}{"body": "fox runs fox"}},
+	}
+	se := NewSearchEngine(docs, Config{})
+	term := func(text string) SpanQuery { return SpanTermQuery{Field: "body", Term: text} }
+
+	tests := []struct {
+		name  string
+		query SpanNearQuery
+		want  map[int][]span
+	}{
+		{"in order", SpanNearQuery{Clauses: []SpanQuery{term("quick"), term("fox")}, Slop: 1, InOrder: true},
+			map[int][]span{0: {{start: 0, end: 3, gaps: 1}}}},
+		{"out of order", SpanNearQuery{Clauses: []SpanQuery{term("fox"), term("quick")}, Slop: 1, InOrder: true},
+			map[int][]span{}},
+		{"unordered", SpanNearQuery{Clauses: []SpanQuery{term("fox"), term("quick")}, Slop: 1},
+			map[int][]span{0: {{start: 0, end: 3, gaps: 1}}}},
+		{"too far", SpanNearQuery{Clauses: []SpanQuery{term("quick"), term("fox")}, Slop: 0},
+			map[int][]span{}},
+		{"same term", SpanNearQuery{Clauses: []SpanQuery{term("fox"), term("fox")}, Slop: 1},
+			map[int][]span{1: {{start: 0, end: 3, gaps: 1}}}},
+		{"nested", SpanNearQuery{Clauses: []SpanQuery{
+			SpanNearQuery{Clauses: []SpanQuery{term("quick"), term("brown")}, InOrder: true},
+			term("jumps"),
+		}, Slop: 1, InOrder: true},
+			map[int][]span{0: {{start: 0, end: 4, gaps: 1}}}},
+	}
+	for _, tt := range tests {
+		if got := tt.query.spans(se); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: spans = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSpanNearQueryLongDocument(t *testing.T) {
+	const n = 1000
+	doc := Document{ID: "a", Fields: map[string]interface{}{"body": strings.Repeat("fox dog ", n)}}
+	se := NewSearchEngine([]Document{doc}, Config{})
+	clauses := []SpanQuery{SpanTermQuery{Field: "body", Term: "fox"}, SpanTermQuery{Field: "body", Term: "dog"}}
+
+	// In order, every fox matches the dog after it; otherwise every dog
+	// also matches the fox after it.
+	if got := len(SpanNearQuery{Clauses: clauses, Slop: 3, InOrder: true}.spans(se)[0]); got != n {
+		t.Errorf("in order: %d matches, want %d", got, n)
+	}
+	if got := len(SpanNearQuery{Clauses: clauses, Slop: 3}.spans(se)[0]); got != 2*n-1 {
+		t.Errorf("unordered: %d matches, want %d", got, 2*n-1)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import "sort"
+
+// SpellCheck configures the correction of query terms that match few
+// documents by the closest terms of the index.
+type SpellCheck struct {
+	// MinDocFreq is the number of documents below which a term is
+	// corrected, 1 if zero, so only terms matching nothing are.
+	MinDocFreq int
+	// MaxEdits is the edit distance of the corrections, 2 if zero.
+	MaxEdits int
+	// AutoCorrect runs the corrected query instead when the query matches
+	// no document.
+	AutoCorrect bool
+}
+
+// DidYouMean returns a query in the syntax of ParseQuery with its rare
+// terms corrected, or false if none needs correcting.
+func (se *SearchEngine) DidYouMean(query string, sc SpellCheck) (string, bool) {
+	q := se.parseSearch(query, se.defaultField)
+	if se.rewrite != nil {
+		q = se.rewrite(q)
+	}
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+	corrected, ok := se.correct(q, sc)
+	if !ok {
+		return "", false
+	}
+	return corrected.String(), true
+}
+
+// correct returns q with the rare terms of its match and term queries
+// replaced by their corrections, or false if there are none. se.rw must be
+// held.
+func (se *SearchEngine) correct(q Query, sc SpellCheck) (Query, bool) {
+	if sc.MinDocFreq <= 0 {
+		sc.MinDocFreq = 1
+	}
+	if sc.MaxEdits <= 0 {
+		sc.MaxEdits = defaultMaxEdits
+	}
+	changed := false
+	corrected := RewriteQuery(q, func(q Query) Query {
+		switch q := q.(type) {
+		case MatchQuery:
+			if text, ok := se.correctText(q.Field, q.Text, sc); ok {
+				q.Text, changed = text, true
+			}
+			return q
+		case TermQuery:
+			if term, ok := se.correctTerm(q.Field, q.Term, sc); ok {
+				q.Term, changed = term, true
+			}
+			return q
+		}
+		return q
+	})
+	return corrected, changed
+}
+
+// correctText corrects the rare terms of text analyzed for field, in
+// place in the text, which is returned as the char filters left it.
+func (se *SearchEngine) correctText(field, text string, sc SpellCheck) (string, bool) {
+	analyzer := se.queryAnalyzer(field)
+	text = analyzer.filterChars(text)
+	tokens := analyzer.analyzeFiltered(text)
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return tokens[i].Start < tokens[j].Start
+	})
+	corrected := ""
+	last := 0
+	for _, token := range tokens {
+		if token.Start < last {
+			continue
+		}
+		if term, ok := se.correctTerm(field, token.Term, sc); ok {
+			corrected += text[last:token.Start] + term
+			last = token.End
+		}
+	}
+	if last == 0 {
+		return "", false
+	}
+	return corrected + text[last:], true
+}
+
+// correctTerm returns the correction of term in field, or in every indexed
+// field if field is empty, if it matches fewer than sc.MinDocFreq
+// documents: the closest term matching more, the most frequent of the
+// closest ones.
+func (se *SearchEngine) correctTerm(field, term string, sc SpellCheck) (string, bool) {
+	fields := []string{field}
+	if field == "" {
+		fields = se.indexedFields()
+	}
+	segments := se.searchableSegments()
+	termFreq := func(term string) int {
+		df := 0
+		for _, field := range fields {
+			df += docFreq(termPostings(segments, field, term))
+		}
+		return df
+	}
+	df := termFreq(term)
+	if df >= sc.MinDocFreq {
+		return "", false
+	}
+
+	distances := make(map[string]int)
+	for _, field := range fields {
+		for _, s := range segments {
+			s.fields[field].WalkFuzzy(term, sc.MaxEdits, func(candidate string, distance int, _ PostingList) bool {
+				if candidate != term {
+					distances[candidate] = distance
+				}
+				return true
+			})
+		}
+	}
+	best, bestDistance, bestFreq := "", 0, df
+	for candidate, distance := range distances {
+		freq := termFreq(candidate)
+		if freq <= df {
+			continue
+		}
+		if best == "" || distance < bestDistance ||
+			distance == bestDistance && (freq > bestFreq || freq == bestFreq && candidate < best) {
+			best, bestDistance, bestFreq = candidate, distance, freq
+		}
+	}
+	return best, best != ""
+}
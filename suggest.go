@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Completion is a term of the index completing a prefix, with the number of
+// documents containing it.
+type Completion struct {
+	Term    string
+	DocFreq int
+}
+
+// Suggest returns the 10 most frequent terms of the default field, or of
+// every indexed field, starting with prefix, for search-box typeahead.
+func (se *SearchEngine) Suggest(prefix string) []Completion {
+	return se.SuggestField(se.defaultField, prefix, defaultSize)
+}
+
+// SuggestField returns the size most frequent terms of field, or of every
+// indexed field if empty, starting with prefix, ties broken by term. The
+// prefix is lowercased but not otherwise analyzed, and terms found only in
+// deleted documents are left out.
+func (se *SearchEngine) SuggestField(field, prefix string, size int) []Completion {
+	if size <= 0 {
+		size = defaultSize
+	}
+	prefix = strings.ToLower(prefix)
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+
+	fields := []string{field}
+	if field == "" {
+		fields = se.indexedFields()
+	}
+	postings := make(map[string][]PostingList)
+	for _, s := range se.searchableSegments() {
+		for _, field := range fields {
+			s.fields[field].WalkPrefix(prefix, func(term string, list PostingList) bool {
+				postings[term] = append(postings[term], list)
+				return true
+			})
+		}
+	}
+	candidates := make([]Completion, 0, len(postings))
+	for term, lists := range postings {
+		candidates = append(candidates, Completion{term, docFreq(lists)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].DocFreq != candidates[j].DocFreq {
+			return candidates[i].DocFreq > candidates[j].DocFreq
+		}
+		return candidates[i].Term < candidates[j].Term
+	})
+
+	var completions []Completion
+	for _, c := range candidates {
+		if len(completions) == size {
+			break
+		}
+		if se.hasLiveDocument(postings[c.Term]) {
+			completions = append(completions, c)
+		}
+	}
+	return completions
+}
+
+// hasLiveDocument reports whether one of lists holds a document that is not
+// deleted.
+func (se *SearchEngine) hasLiveDocument(lists []PostingList) bool {
+	for _, postings := range lists {
+		for it := postings.Iterator(); it.Next(); {
+			if !se.isDeleted(it.Posting().DocID) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// SynonymMap maps a term to the terms it should be expanded with.
+type SynonymMap map[string][]string
+
+// ParseSynonyms reads synonym groups, one per line, as comma-separated
+// equivalent terms ("car,automobile,vehicle"). Blank lines and lines starting
+// with '#' are ignored. Terms are matched against analyzed tokens, so they
+// should be written the way the analyzer emits them (usually lowercase).
+func ParseSynonyms(r io.Reader) (SynonymMap, error) {
+	synonyms := make(SynonymMap)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var group []string
+		for _, term := range strings.Split(line, ",") {
+			if term = strings.TrimSpace(term); term != "" {
+				group = append(group, term)
+			}
+		}
+		for _, term := range group {
+			for _, synonym := range group {
+				if synonym != term && !contains(synonyms[term], synonym) {
+					synonyms[term] = append(synonyms[term], synonym)
+				}
+			}
+		}
+	}
+	return synonyms, scanner.Err()
+}
+
+func LoadSynonyms(path string) (SynonymMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSynonyms(f)
+}
+
+func contains(terms []string, term string) bool {
+	for _, t := range terms {
+		if t == term {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSynonymFilter emits every token followed by its synonyms, which share
+// the position and offsets of the token.
+func NewSynonymFilter(synonyms SynonymMap) TokenFilter {
+	return func(tokens []Token) []Token {
+		expanded := make([]Token, 0, len(tokens))
+		for _, token := range tokens {
+			expanded = append(expanded, token)
+			for _, synonym := range synonyms[token.Term] {
+				expanded = append(expanded, Token{Term: synonym, Position: token.Position, Start: token.Start, End: token.End})
+			}
+		}
+		return expanded
+	}
+}
+
+// WithSynonyms returns a copy of the analyzer that expands synonyms after
+// its own token filters. Use it as the index analyzer to expand at index
+// time, or as Config.SearchAnalyzer to expand at query time.
+func WithSynonyms(analyzer *Analyzer, synonyms SynonymMap) *Analyzer {
+	return analyzer.WithTokenFilters(NewSynonymFilter(synonyms))
+}
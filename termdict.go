@@ -0,0 +1,137 @@
+package main
+
+import "strings"
+
+// termNode is a node of the radix tree behind InvertedIndex. Its label is
+// the part of the term between its parent and itself, so terms sharing a
+// prefix share the nodes of that prefix.
+type termNode struct {
+	label    string
+	children []*termNode // sorted by the first byte of their labels
+	postings PostingList // nil unless a term ends at this node
+}
+
+func (n *termNode) child(c byte) (int, bool) {
+	lo, hi := 0, len(n.children)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if n.children[mid].label[0] < c {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(n.children) && n.children[lo].label[0] == c
+}
+
+func commonPrefixLength(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// Put sets the posting list of a term, replacing any previous one.
+func (idx *InvertedIndex) Put(term string, postings PostingList) {
+	n := &idx.root
+	rest := term
+	for rest != "" {
+		i, ok := n.child(rest[0])
+		if !ok {
+			leaf := &termNode{label: rest, postings: postings}
+			n.children = append(n.children, nil)
+			copy(n.children[i+1:], n.children[i:])
+			n.children[i] = leaf
+			idx.size++
+			return
+		}
+		c := n.children[i]
+		common := commonPrefixLength(rest, c.label)
+		if common < len(c.label) {
+			// Split the child so that the shared part becomes its own node.
+			split := &termNode{label: c.label[:common], children: []*termNode{c}}
+			c.label = c.label[common:]
+			n.children[i] = split
+			c = split
+		}
+		n = c
+		rest = rest[common:]
+	}
+	if n.postings == nil {
+		idx.size++
+	}
+	n.postings = postings
+}
+
+// Get returns the posting list of a term.
+func (idx *InvertedIndex) Get(term string) (PostingList, bool) {
+	if idx == nil {
+		return nil, false
+	}
+	n := &idx.root
+	rest := term
+	for rest != "" {
+		i, ok := n.child(rest[0])
+		if !ok || !strings.HasPrefix(rest, n.children[i].label) {
+			return nil, false
+		}
+		n = n.children[i]
+		rest = rest[len(n.label):]
+	}
+	return n.postings, n.postings != nil
+}
+
+// Len returns the number of terms in the index.
+func (idx *InvertedIndex) Len() int {
+	if idx == nil {
+		return 0
+	}
+	return idx.size
+}
+
+// Walk calls fn for every term in lexicographic byte order until fn returns
+// false.
+func (idx *InvertedIndex) Walk(fn func(term string, postings PostingList) bool) {
+	idx.WalkPrefix("", fn)
+}
+
+// WalkPrefix calls fn in lexicographic byte order for every term starting
+// with prefix until fn returns false.
+func (idx *InvertedIndex) WalkPrefix(prefix string, fn func(term string, postings PostingList) bool) {
+	if idx == nil {
+		return
+	}
+	n := &idx.root
+	path := ""
+	rest := prefix
+	for rest != "" {
+		i, ok := n.child(rest[0])
+		if !ok {
+			return
+		}
+		c := n.children[i]
+		if common := commonPrefixLength(rest, c.label); common < len(rest) && common < len(c.label) {
+			return
+		}
+		n = c
+		path += c.label
+		if len(rest) <= len(c.label) {
+			break
+		}
+		rest = rest[len(c.label):]
+	}
+	walkTerms(n, path, fn)
+}
+
+func walkTerms(n *termNode, term string, fn func(string, PostingList) bool) bool {
+	if n.postings != nil && !fn(term, n.postings) {
+		return false
+	}
+	for _, c := range n.children {
+		if !walkTerms(c, term+c.label, fn) {
+			return false
+		}
+	}
+	return true
+}
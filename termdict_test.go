@@ -0,0 +1,115 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// termDictTerms share prefixes in every way Put has to handle: extending a
+// leaf, splitting an edge, ending inside an edge and ending at a split.
+var termDictTerms = []string{"team", "test", "tea", "toast", "te", "ten", "apple", "t", "tested"}
+
+func newTermDict(terms ...string) *InvertedIndex {
+	idx := &InvertedIndex{}
+	for i, term := range terms {
+		idx.Put(term, Postings(makePostings(i)))
+	}
+	return idx
+}
+
+func walked(walk func(func(string, PostingList) bool)) []string {
+	var terms []string
+	walk(func(term string, _ PostingList) bool {
+		terms = append(terms, term)
+		return true
+	})
+	return terms
+}
+
+func TestInvertedIndexPutGet(t *testing.T) {
+	idx := newTermDict(termDictTerms...)
+	if idx.Len() != len(termDictTerms) {
+		t.Errorf("Len = %d, want %d", idx.Len(), len(termDictTerms))
+	}
+	for i, term := range termDictTerms {
+		list, ok := idx.Get(term)
+		if !ok {
+			t.Errorf("Get(%q) found nothing", term)
+			continue
+		}
+		if got := decodeAll(list); !reflect.DeepEqual(got, makePostings(i)) {
+			t.Errorf("Get(%q) = %v, want %v", term, got, makePostings(i))
+		}
+	}
+	for _, term := range []string{"", "tes", "teams", "tex", "b", "applesauce", "toa"} {
+		if _, ok := idx.Get(term); ok {
+			t.Errorf("Get(%q) found a term that was never put", term)
+		}
+	}
+}
+
+func TestInvertedIndexOverwrite(t *testing.T) {
+	idx := newTermDict("tea", "team")
+	idx.Put("tea", Postings(makePostings(9)))
+	if idx.Len() != 2 {
+		t.Errorf("Len = %d after overwrite, want 2", idx.Len())
+	}
+	list, _ := idx.Get("tea")
+	if got := decodeAll(list); !reflect.DeepEqual(got, makePostings(9)) {
+		t.Errorf("Get(tea) = %v after overwrite", got)
+	}
+}
+
+func TestInvertedIndexWalk(t *testing.T) {
+	idx := newTermDict(termDictTerms...)
+	want := append([]string(nil), termDictTerms...)
+	sort.Strings(want)
+	if got := walked(idx.Walk); !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk = %q, want %q", got, want)
+	}
+
+	var first []string
+	idx.Walk(func(term string, _ PostingList) bool {
+		first = append(first, term)
+		return len(first) < 3
+	})
+	if !reflect.DeepEqual(first, want[:3]) {
+		t.Errorf("stopped Walk = %q, want %q", first, want[:3])
+	}
+}
+
+func TestInvertedIndexWalkPrefix(t *testing.T) {
+	idx := newTermDict(termDictTerms...)
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"apple", "t", "te", "tea", "team", "ten", "test", "tested", "toast"}},
+		{"t", []string{"t", "te", "tea", "team", "ten", "test", "tested", "toast"}},
+		{"te", []string{"te", "tea", "team", "ten", "test", "tested"}},
+		{"tes", []string{"test", "tested"}},
+		{"teste", []string{"tested"}},
+		{"to", []string{"toast"}},
+		{"tested", []string{"tested"}},
+		{"testeds", nil},
+		{"tx", nil},
+		{"b", nil},
+	}
+	for _, tt := range tests {
+		got := walked(func(fn func(string, PostingList) bool) { idx.WalkPrefix(tt.prefix, fn) })
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("WalkPrefix(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestNilInvertedIndex(t *testing.T) {
+	var idx *InvertedIndex
+	if _, ok := idx.Get("a"); ok || idx.Len() != 0 {
+		t.Error("nil index is not empty")
+	}
+	if got := walked(idx.Walk); got != nil {
+		t.Errorf("Walk on nil index = %q", got)
+	}
+}
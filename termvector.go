@@ -0,0 +1,50 @@
+package main
+
+import "fmt"
+
+// TermFreq is a term of a document field with its frequency and, when the
+// index keeps positions, its occurrences.
+type TermFreq struct {
+	Term        string
+	Freq        int
+	Occurrences []Occurrence
+}
+
+// TermVector returns the indexed terms of every field of a document, in
+// lexicographic order. The terms are read back from the index, so they are
+// exactly what queries match against, including for index-only fields.
+func (se *SearchEngine) TermVector(id string) (map[string][]TermFreq, error) {
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+	docID, ok := se.ids[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrDocumentNotFound, id)
+	}
+	return se.termVector(docID), nil
+}
+
+// termVector returns the indexed terms of every field of a document. se.rw
+// must be held.
+func (se *SearchEngine) termVector(docID int) map[string][]TermFreq {
+	vectors := make(map[string][]TermFreq)
+	for _, s := range se.searchableSegments() {
+		if !s.docs.Contains(uint32(docID)) {
+			continue
+		}
+		for field, index := range s.fields {
+			index.Walk(func(term string, postings PostingList) bool {
+				it := postings.Iterator()
+				if it.Advance(docID) && it.Posting().DocID == docID {
+					posting := it.Posting()
+					vectors[field] = append(vectors[field], TermFreq{
+						Term:        term,
+						Freq:        posting.Freq,
+						Occurrences: posting.Occurrences,
+					})
+				}
+				return true
+			})
+		}
+	}
+	return vectors
+}
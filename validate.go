@@ -0,0 +1,207 @@
+package main
+
+// QueryPlan describes a query without running it.
+type QueryPlan struct {
+	// Query is the query tree as it would run, after Config.Rewrite.
+	Query Query
+	// Terms is the number of distinct terms the query looks up, after
+	// analysis and the expansion of wildcards and fuzzy terms.
+	Terms int
+	// Postings is the number of postings those terms hold, plus the
+	// documents that range and match-all queries go through: an estimate
+	// of the work the query takes.
+	Postings int
+}
+
+// ValidateQuery parses a query and returns its plan, or an error wrapping
+// ErrQuerySyntax if it is invalid. Unlike Search, it does not fall back to
+// matching the text of an invalid query.
+func (se *SearchEngine) ValidateQuery(query string) (QueryPlan, error) {
+	q, err := parseQuery(query, se.defaultField, se.mapping())
+	if err != nil {
+		return QueryPlan{}, err
+	}
+	return se.PlanQuery(q), nil
+}
+
+// PlanQuery returns the plan of a query tree.
+func (se *SearchEngine) PlanQuery(q Query) QueryPlan {
+	if se.rewrite != nil {
+		q = se.rewrite(q)
+	}
+	se.rw.RLock()
+	defer se.rw.RUnlock()
+
+	p := newQueryPlanner(se)
+	p.plan(q)
+	return QueryPlan{Query: q, Terms: len(p.seen), Postings: p.postings}
+}
+
+// queryTerms returns the terms a document can match q by, leaving out the
+// terms of MustNot clauses. se.rw must be held.
+func (se *SearchEngine) queryTerms(q Query) []fieldTerm {
+	p := newQueryPlanner(se)
+	p.plan(q)
+	return p.terms
+}
+
+type fieldTerm struct {
+	field, term string
+}
+
+type queryPlanner struct {
+	se       *SearchEngine
+	segments []*segment
+	// seen holds the field:term pairs looked up so far.
+	seen     map[string]bool
+	postings int
+	// terms holds the terms looked up outside of MustNot clauses, the
+	// depth of which is negated.
+	terms    []fieldTerm
+	positive map[string]bool
+	negated  int
+}
+
+func newQueryPlanner(se *SearchEngine) *queryPlanner {
+	return &queryPlanner{
+		se:       se,
+		segments: se.searchableSegments(),
+		seen:     make(map[string]bool),
+		positive: make(map[string]bool),
+	}
+}
+
+func (p *queryPlanner) plan(q Query) {
+	se := p.se
+	switch q := q.(type) {
+	case BooleanQuery:
+		for _, clauses := range [][]Query{q.Must, q.Should, q.Filter} {
+			for _, clause := range clauses {
+				p.plan(clause)
+			}
+		}
+		p.negated++
+		for _, clause := range q.MustNot {
+			p.plan(clause)
+		}
+		p.negated--
+	case BoostQuery:
+		p.plan(q.Query)
+	case RecencyQuery:
+		p.plan(q.Query)
+		p.values(q.Field)
+	case FunctionScoreQuery:
+		p.plan(q.Query)
+	case ScriptScoreQuery:
+		p.plan(q.Query)
+	case ProximityQuery:
+		p.plan(q.Query)
+		p.analyzed(q.Field, q.Text)
+	case RerankQuery:
+		p.plan(q.Query)
+		for _, feature := range q.Features {
+			p.plan(feature)
+		}
+	case DiversifyQuery:
+		p.plan(q.Query)
+	case MatchQuery:
+		p.analyzed(q.Field, q.Text)
+	case PhraseQuery:
+		p.analyzed(q.Field, q.Text)
+	case MultiMatchQuery:
+		fields := q.Fields
+		if len(fields) == 0 {
+			fields = se.indexedFields()
+		}
+		for _, spec := range fields {
+			field, _ := parseFieldBoost(spec)
+			p.analyzed(field, q.Text)
+		}
+	case TermQuery:
+		for _, field := range p.fields(q.Field) {
+			p.term(field, q.Term)
+		}
+	case TermsQuery:
+		for _, field := range p.fields(q.Field) {
+			for _, term := range q.Terms {
+				p.term(field, term)
+			}
+		}
+	case WildcardQuery:
+		for _, field := range p.fields(q.Field) {
+			for _, term := range q.terms(se, field) {
+				p.term(field, term)
+			}
+		}
+	case FuzzyQuery:
+		for _, field := range p.fields(q.Field) {
+			for _, term := range q.terms(se, field) {
+				p.term(field, term)
+			}
+		}
+	case RangeQuery:
+		p.values(q.Field)
+	case DateRangeQuery:
+		p.values(q.Field)
+	case MoreLikeThisQuery:
+		for _, t := range q.terms(se) {
+			p.term(t.field, t.term)
+		}
+	case BoolFieldQuery:
+		p.postings += se.docValues.boolDocs(q.Field, q.Value).Cardinality()
+	case MatchAllQuery:
+		p.postings += se.numLive
+	}
+}
+
+// analyzed looks up the terms of text analyzed for field, in every
+// language if the field is analyzed by language.
+func (p *queryPlanner) analyzed(field, text string) {
+	se := p.se
+	for _, field := range p.fields(field) {
+		analyzers := []*Analyzer{se.queryAnalyzer(field)}
+		if se.languageAnalyzed(field) {
+			analyzers = []*Analyzer{se.searchAnalyzer}
+			for _, analyzer := range se.languages {
+				analyzers = append(analyzers, analyzer)
+			}
+		}
+		for _, analyzer := range analyzers {
+			for _, token := range analyzer.Analyze(text) {
+				p.term(field, token.Term)
+			}
+		}
+	}
+}
+
+// values counts the documents with a value for field that a range query
+// goes through.
+func (p *queryPlanner) values(field string) {
+	for _, s := range p.segments {
+		if s.points == nil {
+			p.postings += s.numDocs()
+		} else {
+			p.postings += len(s.points[field])
+		}
+	}
+}
+
+// fields returns the fields a query on field looks at.
+func (p *queryPlanner) fields(field string) []string {
+	if field == "" {
+		return p.se.indexedFields()
+	}
+	return []string{field}
+}
+
+func (p *queryPlanner) term(field, term string) {
+	key := field + ":" + term
+	if !p.seen[key] {
+		p.seen[key] = true
+		p.postings += docFreq(termPostings(p.segments, field, term))
+	}
+	if p.negated == 0 && !p.positive[key] {
+		p.positive[key] = true
+		p.terms = append(p.terms, fieldTerm{field, term})
+	}
+}
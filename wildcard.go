@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultMaxExpansions caps the number of terms a multi-term query such as
+// a wildcard expands to.
+const defaultMaxExpansions = 128
+
+// WildcardQuery matches the documents containing a term that matches
+// Pattern, where * stands for any sequence of characters and ? for exactly
+// one. The pattern is lowercased but not otherwise analyzed. It expands to
+// at most MaxExpansions terms, 128 if zero, taken in lexicographic order.
+// An empty Field matches every indexed field. Scorer overrides the scorer
+// of the index.
+type WildcardQuery struct {
+	Field         string
+	Pattern       string
+	MaxExpansions int
+	Scorer        Scorer
+}
+
+func (q WildcardQuery) score(se *SearchEngine) map[int]float64 {
+	return se.scoreFields(q.Field, func(field string) map[int]float64 {
+		return se.scoreTokens(field, q.terms(se, field), q.Scorer)
+	})
+}
+
+// terms returns the terms of field the query expands to.
+func (q WildcardQuery) terms(se *SearchEngine, field string) []string {
+	pattern := strings.ToLower(q.Pattern)
+	prefix := pattern
+	if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+		prefix = pattern[:i]
+	}
+	return se.expandTerms(field, prefix, q.MaxExpansions, func(term string) bool {
+		return matchWildcard(pattern, term)
+	})
+}
+
+func (q WildcardQuery) String() string {
+	return fieldPrefix(q.Field) + q.Pattern
+}
+
+// isWildcard reports whether a query word is a wildcard pattern.
+func isWildcard(word string) bool {
+	return strings.ContainsAny(word, "*?")
+}
+
+// expandTerms returns the terms of a field starting with prefix that
+// satisfy match, at most max of them (defaultMaxExpansions if zero) in
+// lexicographic order.
+func (se *SearchEngine) expandTerms(field, prefix string, max int, match func(term string) bool) []string {
+	if max <= 0 {
+		max = defaultMaxExpansions
+	}
+	seen := make(map[string]bool)
+	for _, s := range se.searchableSegments() {
+		// Terms are walked in order, so the first max terms overall are
+		// among the first max of every segment.
+		n := 0
+		s.fields[field].WalkPrefix(prefix, func(term string, postings PostingList) bool {
+			if match(term) {
+				seen[term] = true
+				n++
+			}
+			return n < max
+		})
+	}
+	terms := make([]string, 0, len(seen))
+	for term := range seen {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	if len(terms) > max {
+		terms = terms[:max]
+	}
+	return terms
+}
+
+// matchWildcard reports whether s matches pattern, where * matches any
+// sequence of characters and ? a single one.
+func matchWildcard(pattern, s string) bool {
+	// On a mismatch, retry from the last * with it consuming one more
+	// character of s.
+	star, retry := -1, 0
+	p, i := 0, 0
+	for i < len(s) {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if p < len(pattern) {
+			pc, psize := utf8.DecodeRuneInString(pattern[p:])
+			if pc == '*' {
+				star, retry = p, i
+				p += psize
+				continue
+			}
+			if pc == '?' || pc == c {
+				p += psize
+				i += size
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		_, size = utf8.DecodeRuneInString(s[retry:])
+		retry += size
+		p, i = star+1, retry
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}